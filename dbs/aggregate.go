@@ -0,0 +1,275 @@
+package dbs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// aggregate 执行聚合函数，分组查询时先在每个分组内计算，再汇总所有分组的结果
+func (slt *Selector) aggregate(ctx context.Context, fn string, column string) (any, error) {
+	item, err := slt.buildAggregateSql(fn, column)
+	if err != nil {
+		return nil, err
+	}
+	row, err := slt.queryRow(ctx, item)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return row["mValue"], nil
+}
+
+// buildAggregateSql 生成聚合查询语句
+// 分组查询在原查询中增加每组的聚合结果 mValue，外层再汇总，平均值按总和除以总数计算
+func (slt *Selector) buildAggregateSql(fn string, column string) (*Frame, error) {
+	column = strings.TrimSpace(column)
+	if column == "" {
+		return nil, errors.New("聚合查询缺少字段")
+	}
+	if slt.groups == nil || slt.groups.Sql == "" {
+		wrapColumn := column
+		if i := strings.LastIndex(wrapColumn, "."); i >= 0 {
+			wrapColumn = wrapColumn[i+1:]
+		}
+		return slt.buildAggregate(fn+"("+column+")", fn+"("+wrapColumn+")", "mValue", "AggTempTable"), nil
+	}
+	if slt.unions != nil {
+		return nil, errors.New("分组的联合查询不支持聚合")
+	}
+	inner := fn + "(" + column + ") as mValue"
+	outer := fn + "(mValue)"
+	if fn == "avg" {
+		inner = "sum(" + column + ") as mValue,count(" + column + ") as mCount"
+		outer = "sum(mValue)/sum(mCount)"
+	}
+	fields := slt.fields
+	if fields != nil && fields.Sql != "" {
+		slt.fields = NewFrame(fields.Sql+","+inner, "field", fields.Args...)
+	} else {
+		slt.fields = NewFrame(inner, "field")
+	}
+	orders := slt.orders
+	limit := slt.limit
+	slt.orders = nil
+	slt.limit = ""
+	item := slt.BuildSql(false)
+	slt.fields = fields
+	slt.orders = orders
+	slt.limit = limit
+	item.Sql = "select " + outer + " as mValue from (" + item.Sql + ") AggTempTable"
+	return item, nil
+}
+
+// decimalString 将求和结果转为精确的字符串，NULL 为 "0"
+func decimalString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "0"
+	case string:
+		if v == "" {
+			return "0"
+		}
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return "0"
+}
+
+// toFloat 将查询结果转为浮点数，NULL 为 0
+func toFloat(value any) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	case []byte:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	}
+	return 0
+}
+
+// withFields 临时替换查询字段、排序和限制，返回恢复函数
+func (slt *Selector) withFields(fields string, keepOrder bool, limit string) func() {
+	oldFields := slt.fields
+	oldOrders := slt.orders
+	oldLimit := slt.limit
+	slt.fields = NewFrame(fields, "field")
+	if !keepOrder {
+		slt.orders = nil
+	}
+	if limit != "" {
+		slt.limit = limit
+	}
+	return func() {
+		slt.fields = oldFields
+		slt.orders = oldOrders
+		slt.limit = oldLimit
+	}
+}
+
+/*
+*
+求和，DECIMAL 字段需要精确结果时使用 SumDecimal
+*/
+func (slt *Selector) Sum(column string) (float64, error) {
+	value, err := slt.aggregate(slt.context(), "sum", column)
+	return toFloat(value), err
+}
+
+/*
+*
+求和并以字符串返回精确结果，不经过浮点数转换，没有数据时为 "0"
+*/
+func (slt *Selector) SumDecimal(column string) (string, error) {
+	value, err := slt.aggregate(WithFetchMode(slt.context(), FetchTyped), "sum", column)
+	if err != nil {
+		return "", err
+	}
+	return decimalString(value), nil
+}
+
+/*
+*
+求平均值
+*/
+func (slt *Selector) Avg(column string) (float64, error) {
+	value, err := slt.aggregate(slt.context(), "avg", column)
+	return toFloat(value), err
+}
+
+/*
+*
+最大值，没有数据时为 NULL 对应的值
+*/
+func (slt *Selector) Max(column string) (any, error) {
+	return slt.aggregate(slt.context(), "max", column)
+}
+
+/*
+*
+最小值，没有数据时为 NULL 对应的值
+*/
+func (slt *Selector) Min(column string) (any, error) {
+	return slt.aggregate(slt.context(), "min", column)
+}
+
+/*
+*
+是否存在符合条件的数据
+*/
+func (slt *Selector) Exists() (bool, error) {
+	restore := slt.withFields("1", false, "limit 1")
+	item := slt.BuildSql(false)
+	restore()
+	row, err := slt.queryRow(slt.context(), item)
+	if err != nil {
+		return false, err
+	}
+	return row != nil, nil
+}
+
+/*
+*
+获取第一行的单个字段值，没有数据时返回 nil
+*/
+func (slt *Selector) Value(column string) (any, error) {
+	if err := slt.Err(); err != nil {
+		return nil, err
+	}
+	restore := slt.withFields(column, true, "limit 1")
+	item := slt.BuildSql(false)
+	restore()
+	rows, err := slt.db.QueryRowsContext(slt.context(), item.Sql, item.Args...)
+	if err != nil {
+		return nil, err
+	}
+	list, err := fetch(rows, slt.db.fetchModeOf(slt.context()))
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	for _, value := range list[0] {
+		return value, nil
+	}
+	return nil, nil
+}
+
+/*
+*
+获取单个字段的值列表，dest 为切片指针，例如 *[]int、*[]string
+*/
+func (slt *Selector) Pluck(column string, dest any) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Slice {
+		return errors.New("接收对象必须是切片指针")
+	}
+	if err := slt.Err(); err != nil {
+		return err
+	}
+	restore := slt.withFields(column, true, "")
+	item := slt.BuildSql(true)
+	restore()
+	rows, err := slt.db.QueryRowsContext(slt.context(), item.Sql, item.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	slice := value.Elem()
+	elemType := slice.Type().Elem()
+	list := reflect.MakeSlice(slice.Type(), 0, 0)
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		if err = rows.Scan(elem.Interface()); err != nil {
+			return err
+		}
+		list = reflect.Append(list, elem.Elem())
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	slice.Set(list)
+	return nil
+}
+
+/*
+*
+获取查询结果并以 column 字段值为键
+*/
+func (slt *Selector) KeyBy(column string) (map[any]H, error) {
+	list, err := slt.GetList()
+	if err != nil {
+		return nil, err
+	}
+	key := column
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		key = key[i+1:]
+	}
+	key = strings.Trim(key, "`")
+	result := make(map[any]H, len(list))
+	for _, row := range list {
+		value := row[key]
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		result[value] = row
+	}
+	return result, nil
+}
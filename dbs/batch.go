@@ -0,0 +1,248 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPlaceholders MySQL 预处理语句最多支持的参数个数
+const maxPlaceholders = 65535
+
+// BatchOptions 批量插入选项
+type BatchOptions struct {
+	ChunkSize int  // 每条语句最多插入的行数，默认 500
+	MaxPacket int  // 每条语句最大字节数，默认读取 max_allowed_packet
+	Ignore    bool // 使用 insert ignore
+	Tx        bool // 在事务中执行，任一分块失败全部回滚，仅对 DB 有效
+}
+
+// batchColumns 获取并校验所有行的字段
+func batchColumns(list []H) ([]string, error) {
+	if len(list) == 0 {
+		return nil, errors.New("插入失败，没有相应的数据")
+	}
+	columns := make([]string, 0, len(list[0]))
+	for key := range list[0] {
+		columns = append(columns, key)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("插入失败，没有相应的数据")
+	}
+	sort.Strings(columns)
+	for i, row := range list {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("批量插入失败，第 %d 行字段与第 1 行不一致", i+1)
+		}
+		for _, key := range columns {
+			if _, ok := row[key]; !ok {
+				return nil, fmt.Errorf("批量插入失败，第 %d 行缺少字段 %s", i+1, key)
+			}
+		}
+	}
+	return columns, nil
+}
+
+// argSize 估算参数占用的字节数
+func argSize(value any) int {
+	switch v := value.(type) {
+	case string:
+		return len(v) + 4
+	case []byte:
+		return len(v) + 4
+	case *Frame:
+		return len(v.Format())
+	default:
+		return 16
+	}
+}
+
+// buildBatch 生成分块后的批量插入语句
+func buildBatch(table string, list []H, opts *BatchOptions) ([]*Frame, error) {
+	columns, err := batchColumns(list)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 500
+	}
+	if limit := maxPlaceholders / len(columns); chunkSize > limit {
+		chunkSize = limit
+	}
+	names := make([]string, 0, len(columns))
+	for _, key := range columns {
+		names = append(names, QuoteIdent(key))
+	}
+	verb := "insert into "
+	if opts.Ignore {
+		verb = "insert ignore into "
+	}
+	head := verb + QuoteIdent(table) + " (" + strings.Join(names, ",") + ") values "
+	frames := make([]*Frame, 0)
+	var frame *Frame
+	var rows []string
+	size := 0
+	flush := func() {
+		if frame != nil {
+			frame.Sql = head + strings.Join(rows, ",")
+			frames = append(frames, frame)
+		}
+		frame = nil
+		rows = nil
+		size = len(head)
+	}
+	flush()
+	for i, row := range list {
+		temps := make([]string, 0, len(columns))
+		values := make([]any, 0, len(columns))
+		rowSize := 3
+		for _, key := range columns {
+			value := row[key]
+			rowSize += argSize(value)
+			if f, ok := value.(*Frame); ok {
+				temps = append(temps, f.Format())
+				continue
+			}
+			temps = append(temps, "?")
+			values = append(values, value)
+		}
+		if opts.MaxPacket > 0 && len(head)+rowSize > opts.MaxPacket {
+			return nil, fmt.Errorf("批量插入失败，第 %d 行数据超过 max_allowed_packet", i+1)
+		}
+		if frame != nil && (len(rows) >= chunkSize || (opts.MaxPacket > 0 && size+rowSize > opts.MaxPacket)) {
+			flush()
+		}
+		if frame == nil {
+			frame = NewFrame("", "batch")
+		}
+		rows = append(rows, "("+strings.Join(temps, ",")+")")
+		frame.Args = append(frame.Args, values...)
+		size += rowSize
+	}
+	flush()
+	return frames, nil
+}
+
+// batchExecutor 批量插入所需的执行对象，DB 和 Tx 均实现该接口
+type batchExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	prepareWrite(ctx context.Context, table string, data H) (H, error)
+	maxPacket(ctx context.Context) int
+	invalidate(table string)
+}
+
+// toInt64 将查询结果转为整数，兼容字符串和无符号类型
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// maxPacket 读取主库的 max_allowed_packet 并缓存，预留部分空间，读取失败时使用 4MB
+func (db *DB) maxPacket(ctx context.Context) int {
+	size := db.packet.Load()
+	if size <= 0 {
+		size = 4 << 20
+		row, err := db.QueryRowContext(ForcePrimary(ctx), "select @@max_allowed_packet as packet")
+		if err == nil && row != nil {
+			if v, ok := toInt64(row["packet"]); ok && v > 0 {
+				size = v
+				db.packet.Store(v)
+			}
+		}
+	}
+	if size > math.MaxInt32 {
+		size = math.MaxInt32
+	}
+	return int(size) - 1024
+}
+
+// maxPacket 使用所属连接缓存的 max_allowed_packet
+func (tx *Tx) maxPacket(ctx context.Context) int {
+	return tx.db.maxPacket(ctx)
+}
+
+func insertBatch(ctx context.Context, ex batchExecutor, table string, list []H, opts *BatchOptions) ([]sql.Result, error) {
+	temp := BatchOptions{}
+	if opts != nil {
+		temp = *opts
+	}
+	rows := make([]H, 0, len(list))
+	for _, row := range list {
+		row, err := ex.prepareWrite(ctx, table, row)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if temp.MaxPacket <= 0 {
+		temp.MaxPacket = ex.maxPacket(ctx)
+	}
+	frames, err := buildBatch(table, rows, &temp)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]sql.Result, 0, len(frames))
+	defer ex.invalidate(table)
+	for _, frame := range frames {
+		res, err := ex.ExecContext(ctx, frame.Sql, frame.Args...)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// InsertBatch 批量插入数据集，按行数和包大小分块执行，返回每个分块的结果
+func (db *DB) InsertBatch(table string, list []H, opts *BatchOptions) ([]sql.Result, error) {
+	return db.InsertBatchContext(context.Background(), table, list, opts)
+}
+
+// InsertBatchContext 带有上下文批量插入数据集
+func (db *DB) InsertBatchContext(ctx context.Context, table string, list []H, opts *BatchOptions) ([]sql.Result, error) {
+	if opts == nil || !opts.Tx {
+		return insertBatch(ctx, db, table, list, opts)
+	}
+	var results []sql.Result
+	err := db.TransactionContext(ctx, func(tx *Tx) error {
+		var err error
+		results, err = insertBatch(ctx, tx, table, list, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// InsertBatch 批量插入数据集，按行数和包大小分块执行，返回每个分块的结果
+func (tx *Tx) InsertBatch(table string, list []H, opts *BatchOptions) ([]sql.Result, error) {
+	return tx.InsertBatchContext(context.Background(), table, list, opts)
+}
+
+// InsertBatchContext 带有上下文批量插入数据集
+func (tx *Tx) InsertBatchContext(ctx context.Context, table string, list []H, opts *BatchOptions) ([]sql.Result, error) {
+	return insertBatch(ctx, tx, table, list, opts)
+}
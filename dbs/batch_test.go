@@ -0,0 +1,107 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildBatch(t *testing.T) {
+	list := make([]H, 0)
+	for i := 0; i < 5; i++ {
+		list = append(list, H{"name": "user", "age": i})
+	}
+	frames, err := buildBatch("user", list, &BatchOptions{ChunkSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("chunks = %d, want 3", len(frames))
+	}
+	want := "insert into `user` (`age`,`name`) values (?,?),(?,?)"
+	if frames[0].Sql != want {
+		t.Fatalf("sql = %s", frames[0].Sql)
+	}
+	if !reflect.DeepEqual(frames[2].Args, []any{4, "user"}) {
+		t.Fatalf("args = %v", frames[2].Args)
+	}
+	frames, err = buildBatch("user", list, &BatchOptions{MaxPacket: 120, Ignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) < 2 || !strings.HasPrefix(frames[0].Sql, "insert ignore into") {
+		t.Fatalf("packet chunks = %d", len(frames))
+	}
+}
+
+func TestBuildBatchColumns(t *testing.T) {
+	list := []H{{"a": 1, "b": 2}, {"a": 1, "c": 2}}
+	if _, err := buildBatch("t", list, &BatchOptions{}); err == nil {
+		t.Fatal("mismatched columns should be rejected")
+	}
+	if _, err := buildBatch("t", nil, &BatchOptions{}); err == nil {
+		t.Fatal("empty list should be rejected")
+	}
+}
+
+func TestWriteContextCanceled(t *testing.T) {
+	sqlDb, err := sql.Open("mysql", "root@tcp(127.0.0.1:1)/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{DB: sqlDb}
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	type user struct {
+		Id   int    `db:"id,pk,auto"`
+		Name string `db:"name"`
+	}
+	_, err = db.InsertStructContext(ctx, "user", &user{Name: "a"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("insert struct err = %v", err)
+	}
+	_, err = db.UpsertContext(ctx, "user", H{"name": "a"}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("upsert err = %v", err)
+	}
+	_, err = db.InsertBatchContext(ctx, "user", []H{{"name": "a"}}, &BatchOptions{MaxPacket: 1024})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("batch err = %v", err)
+	}
+}
+
+func TestMaxPacket(t *testing.T) {
+	packets := 0
+	srv := &fakeServer{query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		packets++
+		return []string{"packet"}, [][]driver.Value{{"2048"}}, nil
+	}}
+	db := newFakeDB(srv)
+	list := make([]H, 0)
+	for i := 0; i < 40; i++ {
+		list = append(list, H{"name": strings.Repeat("a", 40)})
+	}
+	results, err := db.InsertBatch("user", list, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) < 2 {
+		t.Fatalf("chunks = %d, packet size should be read from the server", len(results))
+	}
+	if _, err = db.InsertBatch("user", list, &BatchOptions{Tx: true}); err != nil {
+		t.Fatal(err)
+	}
+	if packets != 1 {
+		t.Fatalf("max_allowed_packet read %d times", packets)
+	}
+	for _, value := range []any{uint64(2048), int64(2048), []byte("2048"), "2048"} {
+		if v, ok := toInt64(value); !ok || v != 2048 {
+			t.Fatalf("toInt64(%#v) = %d, %v", value, v, ok)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package dbs
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache 查询结果缓存接口，tags 为结果依赖的表名，用于写入时失效
+type Cache interface {
+	Get(key string) (any, bool)
+	Set(key string, value any, ttl time.Duration, tags []string)
+	Invalidate(tags ...string)
+}
+
+type memoryItem struct {
+	key     string
+	value   any
+	expires time.Time
+	tags    []string
+}
+
+// MemoryCache 进程内的 LRU 缓存
+type MemoryCache struct {
+	mutex sync.Mutex
+	size  int
+	list  *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+}
+
+// NewMemoryCache 创建最多保存 size 个结果的内存缓存
+func NewMemoryCache(size int) *MemoryCache {
+	if size < 1 {
+		size = 1000
+	}
+	return &MemoryCache{
+		size:  size,
+		list:  list.New(),
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (any, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*memoryItem)
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.list.MoveToFront(el)
+	return item.value, true
+}
+
+func (c *MemoryCache) Set(key string, value any, ttl time.Duration, tags []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	item := &memoryItem{key: key, value: value, tags: tags}
+	if ttl > 0 {
+		item.expires = time.Now().Add(ttl)
+	}
+	c.items[key] = c.list.PushFront(item)
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	for c.list.Len() > c.size {
+		c.remove(c.list.Back())
+	}
+}
+
+func (c *MemoryCache) Invalidate(tags ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if el, ok := c.items[key]; ok {
+				c.remove(el)
+			}
+		}
+		delete(c.tags, tag)
+	}
+}
+
+// Len 返回缓存的结果数量
+func (c *MemoryCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.list.Len()
+}
+
+func (c *MemoryCache) remove(el *list.Element) {
+	item := el.Value.(*memoryItem)
+	c.list.Remove(el)
+	delete(c.items, item.key)
+	for _, tag := range item.tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  []H
+	err  error
+}
+
+// flightGroup 合并相同键的并发查询，只有第一个调用会真正执行
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+// detachedContext 保留上下文中的值，但不继承取消和超时
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// do 执行或等待相同键的查询，查询使用不会被取消的上下文在单独的协程中执行
+// 每个调用方只受自己的上下文控制，取消后立即返回，不影响其他等待的调用方
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) ([]H, error)) ([]H, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			call.val, call.err = fn(detachedContext{ctx})
+			g.mutex.Lock()
+			delete(g.calls, key)
+			g.mutex.Unlock()
+			close(call.done)
+		}()
+	}
+	g.mutex.Unlock()
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SetCache 设置查询结果缓存，nil 表示使用默认的内存缓存
+func (db *DB) SetCache(cache Cache) *DB {
+	db.cacheMutex.Lock()
+	db.cache = cache
+	db.cacheMutex.Unlock()
+	return db
+}
+
+// getCache 返回查询结果缓存，未设置时创建默认的内存缓存
+func (db *DB) getCache() Cache {
+	db.cacheMutex.Lock()
+	defer db.cacheMutex.Unlock()
+	if db.cache == nil {
+		db.cache = NewMemoryCache(1000)
+	}
+	return db.cache
+}
+
+// cacheTag 将表名转为缓存标签，替换表前缀并去掉反引号
+func (db *DB) cacheTag(table string) string {
+	table = strings.ReplaceAll(strings.TrimSpace(table), "`", "")
+	return strings.ToLower(strings.Replace(table, "@pf_", db.prefix, -1))
+}
+
+// invalidate 使依赖该表的缓存失效并增加失效版本，未使用缓存时不做处理
+func (db *DB) invalidate(table string) {
+	fields := strings.Fields(table)
+	if len(fields) == 0 {
+		return
+	}
+	tag := db.cacheTag(fields[0])
+	db.cacheMutex.Lock()
+	cache := db.cache
+	if cache != nil {
+		if db.cacheGens == nil {
+			db.cacheGens = make(map[string]uint64)
+		}
+		db.cacheGens[tag]++
+	}
+	db.cacheMutex.Unlock()
+	if cache == nil {
+		return
+	}
+	cache.Invalidate(tag)
+}
+
+// cacheGen 返回标签当前的失效版本之和，查询前后不一致说明期间有写入
+func (db *DB) cacheGen(tags []string) uint64 {
+	db.cacheMutex.Lock()
+	defer db.cacheMutex.Unlock()
+	return db.sumGens(tags)
+}
+
+func (db *DB) sumGens(tags []string) uint64 {
+	sum := uint64(0)
+	for _, tag := range tags {
+		sum += db.cacheGens[tag]
+	}
+	return sum
+}
+
+// setCache 查询期间相关的表没有被写入时才缓存结果
+func (db *DB) setCache(cache Cache, key string, list []H, ttl time.Duration, tags []string, gen uint64) {
+	db.cacheMutex.Lock()
+	defer db.cacheMutex.Unlock()
+	if db.sumGens(tags) != gen {
+		return
+	}
+	cache.Set(key, list, ttl, tags)
+}
+
+// invalidate 事务提交后使依赖该表的缓存失效
+func (tx *Tx) invalidate(table string) {
+	tx.AfterCommit(func() {
+		tx.db.invalidate(table)
+	})
+}
+
+// copyRows 复制结果集，避免调用方修改缓存中的数据
+func copyRows(list []H) []H {
+	if list == nil {
+		return nil
+	}
+	rows := make([]H, len(list))
+	for i, row := range list {
+		temp := make(H, len(row))
+		for k, v := range row {
+			temp[k] = v
+		}
+		rows[i] = temp
+	}
+	return rows
+}
+
+/*
+*
+缓存查询结果，ttl 为有效期，key 为缓存键前缀，可为空
+实际的缓存键由查询语句和参数生成，对相关表的 Insert、Update、Delete 会使缓存失效
+*/
+func (slt *Selector) Cache(ttl time.Duration, key string) *Selector {
+	slt.cacheTTL = ttl
+	slt.cacheKey = key
+	slt.useCache = true
+	return slt
+}
+
+// tableTags 收集表名或子查询中用到的表
+func tableTags(table any) []string {
+	switch v := table.(type) {
+	case string:
+		if fields := strings.Fields(v); len(fields) > 0 {
+			return []string{fields[0]}
+		}
+	case *Selector:
+		return v.cacheTags()
+	}
+	return nil
+}
+
+// selectorTags 收集参数中子查询用到的表
+func selectorTags(args ...any) []string {
+	var tags []string
+	for _, arg := range args {
+		if v, ok := arg.(*Selector); ok {
+			tags = append(tags, v.cacheTags()...)
+		}
+	}
+	return tags
+}
+
+// cacheTags 返回查询依赖的所有表，包括条件和 having 中的子查询
+func (slt *Selector) cacheTags() []string {
+	tags := append([]string(nil), slt.tags...)
+	tags = append(tags, slt.Condition.tags...)
+	if slt.having != nil {
+		tags = append(tags, slt.having.tags...)
+	}
+	return tags
+}
+
+// query 执行查询，开启缓存时优先读取缓存，并发的相同查询只执行一次
+// 读取模式不同的查询使用不同的缓存键，强制读主库时不使用缓存
+func (slt *Selector) query(ctx context.Context, frame *Frame) ([]H, error) {
+	if err := slt.Err(); err != nil {
+		return nil, err
+	}
+	if !slt.useCache || isForcePrimary(ctx) {
+		return slt.db.QueryContext(ctx, frame.Sql, frame.Args...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cache := slt.db.getCache()
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%d\x00%s\x00%#v", slt.db.name, slt.db.fetchModeOf(ctx), frame.Sql, frame.Args)))
+	key := "goyee:" + slt.cacheKey + ":" + hex.EncodeToString(sum[:])
+	if value, ok := cache.Get(key); ok {
+		if list, ok := value.([]H); ok {
+			return copyRows(list), nil
+		}
+	}
+	list, err := slt.db.flight.do(ctx, key, func(ctx context.Context) ([]H, error) {
+		tables := slt.cacheTags()
+		tags := make([]string, 0, len(tables))
+		for _, table := range tables {
+			tags = append(tags, slt.db.cacheTag(table))
+		}
+		gen := slt.db.cacheGen(tags)
+		list, err := slt.db.QueryContext(ctx, frame.Sql, frame.Args...)
+		if err != nil {
+			return nil, err
+		}
+		slt.db.setCache(cache, key, list, slt.cacheTTL, tags, gen)
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyRows(list), nil
+}
+
+// queryRow 执行查询并返回第一行，没有数据时返回 nil
+func (slt *Selector) queryRow(ctx context.Context, frame *Frame) (H, error) {
+	list, err := slt.query(ctx, frame)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return list[0], nil
+}
@@ -0,0 +1,173 @@
+package dbs
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", 1, 0, []string{"user"})
+	cache.Set("b", 2, 0, []string{"role"})
+	cache.Get("a")
+	cache.Set("c", 3, 0, []string{"user", "role"})
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b should be evicted")
+	}
+	cache.Invalidate("user")
+	if cache.Len() != 0 {
+		t.Fatalf("len = %d", cache.Len())
+	}
+	cache.Set("d", 4, time.Millisecond, nil)
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := cache.Get("d"); ok {
+		t.Fatal("d should be expired")
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	group := &flightGroup{}
+	var calls atomic.Int32
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			list, err := group.do(context.Background(), "k", func(context.Context) ([]H, error) {
+				calls.Add(1)
+				<-start
+				return []H{{"id": 1}}, nil
+			})
+			if err != nil || len(list) != 1 {
+				t.Error("unexpected result", list, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(start)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("calls = %d", n)
+	}
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	group := &flightGroup{}
+	start := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	var queryErr atomic.Value
+	go func() {
+		group.do(ctx, "k", func(ctx context.Context) ([]H, error) {
+			<-start
+			if err := ctx.Err(); err != nil {
+				queryErr.Store(err)
+			}
+			return []H{{"id": 1}}, nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	done := make(chan []H)
+	go func() {
+		list, _ := group.do(context.Background(), "k", nil)
+		done <- list
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(start)
+	if list := <-done; len(list) != 1 {
+		t.Fatalf("waiter should receive the result: %v", list)
+	}
+	if queryErr.Load() != nil {
+		t.Fatal("the shared query should not be canceled by the first caller")
+	}
+	if _, err := group.do(ctx, "k2", func(ctx context.Context) ([]H, error) {
+		<-start
+		return nil, nil
+	}); err != context.Canceled {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestCacheFetchMode(t *testing.T) {
+	calls := 0
+	srv := &fakeServer{query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		calls++
+		return []string{"name"}, [][]driver.Value{{nil}}, nil
+	}}
+	db := newFakeDB(srv)
+	list, err := NewSelector(db, "@pf_user").Cache(time.Minute, "").GetList()
+	if err != nil || list[0]["name"] != "" {
+		t.Fatalf("list = %v, err = %v", list, err)
+	}
+	typed := NewSelector(db, "@pf_user").Cache(time.Minute, "").WithContext(WithFetchMode(context.Background(), FetchTyped))
+	if list, err = typed.GetList(); err != nil || list[0]["name"] != nil {
+		t.Fatalf("typed list = %v, err = %v", list, err)
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d", calls)
+	}
+}
+
+func TestSelectorTags(t *testing.T) {
+	db := &DB{prefix: "sd_"}
+	sub := NewSelector(db, "@pf_order")
+	slt := NewSelector(db, "@pf_user u").LeftJoin(sub.As("o")).JoinOn("o.uid=u.id")
+	if !reflect.DeepEqual(slt.tags, []string{"@pf_user", "@pf_order"}) {
+		t.Fatalf("tags = %v", slt.tags)
+	}
+	if tag := db.cacheTag("`@pf_User`"); tag != "sd_user" {
+		t.Fatalf("tag = %s", tag)
+	}
+	vip := NewSelector(db, "@pf_vip").Field("uid")
+	exists := NewSelector(db, "@pf_log")
+	slt = NewSelector(db, "@pf_user")
+	slt.In("id", vip)
+	slt.WhereC(NewCondition().Exists(exists))
+	slt.Union(NewSelector(db, "@pf_guest"))
+	if tags := slt.cacheTags(); !reflect.DeepEqual(tags, []string{"@pf_user", "@pf_guest", "@pf_vip", "@pf_log"}) {
+		t.Fatalf("subquery tags = %v", tags)
+	}
+}
+
+func TestCacheInvalidateDuringQuery(t *testing.T) {
+	calls := 0
+	var db *DB
+	srv := &fakeServer{query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		calls++
+		if calls == 1 {
+			// 查询执行期间写入了子查询中的表
+			db.invalidate("@pf_vip")
+		}
+		return []string{"id"}, [][]driver.Value{{int64(calls)}}, nil
+	}}
+	db = newFakeDB(srv)
+	get := func(ctx context.Context) H {
+		slt := NewSelector(db, "@pf_user").Cache(time.Minute, "").WithContext(ctx)
+		slt.In("id", NewSelector(db, "@pf_vip").Field("uid"))
+		list, err := slt.GetList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list[0]
+	}
+	get(context.Background())
+	if row := get(context.Background()); row["id"] != 2 {
+		t.Fatalf("result read during invalidation should not be cached: %v", row)
+	}
+	if row := get(context.Background()); row["id"] != 2 {
+		t.Fatalf("result should be cached: %v", row)
+	}
+	if row := get(ForcePrimary(context.Background())); row["id"] != 3 {
+		t.Fatalf("force primary should skip the cache: %v", row)
+	}
+	db.invalidate("@pf_vip")
+	if row := get(context.Background()); row["id"] != 4 {
+		t.Fatalf("subquery table write should invalidate: %v", row)
+	}
+}
@@ -0,0 +1,238 @@
+package dbs
+
+import (
+	"context"
+	"errors"
+	"github.com/wj008/goyee/worker"
+	"strings"
+	"sync"
+)
+
+// ChunkState 分块处理进度，LastKey 可用于 ChunkFrom 断点续跑
+type ChunkState struct {
+	Chunks  int // 已完成的块数
+	Rows    int // 已完成的行数
+	LastKey any // 已完成的最大键值
+}
+
+/*
+*
+设置分块遍历使用的键，必须唯一且有索引，默认为 id
+*/
+func (slt *Selector) ChunkKey(key string) *Selector {
+	slt.chunkKey = strings.TrimSpace(key)
+	return slt
+}
+
+/*
+*
+设置分块遍历的起始键值，只处理键值大于 start 的数据
+*/
+func (slt *Selector) ChunkFrom(start any) *Selector {
+	slt.chunkStart = start
+	return slt
+}
+
+/*
+*
+设置分块处理的进度回调
+*/
+func (slt *Selector) OnChunk(fn func(state ChunkState)) *Selector {
+	slt.chunkProgress = fn
+	return slt
+}
+
+// chunkColumn 返回转义后的键字段和结果中的键名，键名必须是合法的标识符
+func (slt *Selector) chunkColumn() (string, string, error) {
+	column := slt.chunkKey
+	if column == "" {
+		column = "id"
+	}
+	if !ValidIdent(column) {
+		return "", "", errors.New("分块遍历的键名错误: " + column)
+	}
+	key := column
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		key = key[i+1:]
+	}
+	return QuoteIdent(column), key, nil
+}
+
+// nextChunk 读取键值大于 last 的下一块数据
+func (slt *Selector) nextChunk(ctx context.Context, column string, last any, size int) ([]H, error) {
+	if err := slt.Err(); err != nil {
+		return nil, err
+	}
+	items := slt.Condition.items
+	orders := slt.orders
+	limit := slt.limit
+	defer func() {
+		slt.Condition.items = items
+		slt.orders = orders
+		slt.limit = limit
+	}()
+	if last != nil {
+		slt.Condition.items = appendGroup(items, NewFrame(column+" > ?", "where", last))
+	}
+	slt.orders = nil
+	slt.Order(column + " asc")
+	slt.Limit(0, size)
+	frame := slt.BuildSql(false)
+	return slt.db.QueryContext(ctx, frame.Sql, frame.Args...)
+}
+
+// chunkLastKey 获取块中最后一行的键值，查询结果必须包含不为 NULL 的键
+func chunkLastKey(list []H, key string) (any, error) {
+	value := list[len(list)-1][key]
+	if value == nil {
+		return nil, errors.New("分块查询结果缺少键 " + key + " 或键值为 NULL")
+	}
+	return value, nil
+}
+
+/*
+*
+按键值升序分块遍历数据，fn 返回 ErrStop 时提前结束
+不使用 offset，遍历过程中数据被修改也不会跳过或重复
+*/
+func (slt *Selector) Chunk(size int, fn func([]H) error) error {
+	return slt.ChunkContext(slt.context(), size, fn)
+}
+
+/*
+*
+带有上下文分块遍历数据
+*/
+func (slt *Selector) ChunkContext(ctx context.Context, size int, fn func([]H) error) error {
+	if size < 1 {
+		size = 100
+	}
+	column, key, err := slt.chunkColumn()
+	if err != nil {
+		return err
+	}
+	state := ChunkState{LastKey: slt.chunkStart}
+	for {
+		list, err := slt.nextChunk(ctx, column, state.LastKey, size)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		last, err := chunkLastKey(list, key)
+		if err != nil {
+			return err
+		}
+		if err = fn(list); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+		state.Chunks++
+		state.Rows += len(list)
+		state.LastKey = last
+		if slt.chunkProgress != nil {
+			slt.chunkProgress(state)
+		}
+		if len(list) < size {
+			return nil
+		}
+	}
+}
+
+/*
+*
+按键值分块读取数据，并交给 workers 个 worker.Queue 协程并行处理
+进度中的 LastKey 为之前所有块均已完成的最大键值，可安全用于断点续跑
+*/
+func (slt *Selector) ChunkParallel(workers int, size int, fn func([]H) error) error {
+	return slt.ChunkParallelContext(slt.context(), workers, size, fn)
+}
+
+/*
+*
+带有上下文分块并行处理数据
+*/
+func (slt *Selector) ChunkParallelContext(ctx context.Context, workers int, size int, fn func([]H) error) error {
+	if size < 1 {
+		size = 100
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	column, key, err := slt.chunkColumn()
+	if err != nil {
+		return err
+	}
+	var mutex sync.Mutex
+	var firstErr error
+	state := ChunkState{LastKey: slt.chunkStart}
+	done := make(map[int][]H)
+	next := 0
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return firstErr != nil
+	}
+	queue := worker.NewQueue(workers, func(value ...any) {
+		seq := value[0].(int)
+		list := value[1].([]H)
+		if failed() {
+			return
+		}
+		err := fn(list)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		done[seq] = list
+		for {
+			item, ok := done[next]
+			if !ok {
+				break
+			}
+			delete(done, next)
+			next++
+			state.Chunks++
+			state.Rows += len(item)
+			state.LastKey = item[len(item)-1][key]
+			if slt.chunkProgress != nil {
+				slt.chunkProgress(state)
+			}
+		}
+	})
+	last := slt.chunkStart
+	var readErr error
+	for seq := 0; !failed(); seq++ {
+		list, err := slt.nextChunk(ctx, column, last, size)
+		if err != nil {
+			readErr = err
+			break
+		}
+		if len(list) == 0 {
+			break
+		}
+		if last, err = chunkLastKey(list, key); err != nil {
+			readErr = err
+			break
+		}
+		queue.Add(seq, list)
+		if len(list) < size {
+			break
+		}
+	}
+	queue.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if errors.Is(firstErr, ErrStop) {
+		return nil
+	}
+	return firstErr
+}
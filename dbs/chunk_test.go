@@ -0,0 +1,109 @@
+package dbs
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// chunkServer 模拟 id 为 1 到 count 的数据，按 `id` > ? 和 limit 返回
+func chunkServer(count int) (*fakeServer, *[]string) {
+	queries := make([]string, 0)
+	srv := &fakeServer{}
+	srv.query = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		queries = append(queries, query)
+		start := int64(0)
+		if strings.Contains(query, "`id` > ?") {
+			start = args[len(args)-1].Value.(int64)
+		}
+		rows := make([][]driver.Value, 0)
+		for id := start + 1; id <= int64(count) && len(rows) < 2; id++ {
+			rows = append(rows, []driver.Value{id})
+		}
+		return []string{"id"}, rows, nil
+	}
+	return srv, &queries
+}
+
+func TestChunk(t *testing.T) {
+	srv, queries := chunkServer(5)
+	slt := NewSelector(newFakeDB(srv), "@pf_user")
+	slt.Where("status=?", 1).OrWhere("vip=?", 1)
+	states := make([]ChunkState, 0)
+	slt.OnChunk(func(state ChunkState) {
+		states = append(states, state)
+	})
+	rows := 0
+	err := slt.Chunk(2, func(list []H) error {
+		rows += len(list)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 5 || len(states) != 3 || states[2].LastKey != 5 || states[2].Rows != 5 {
+		t.Fatalf("rows = %d, states = %+v", rows, states)
+	}
+	want := "select * from `user` where (status=? or vip=?) and `id` > ? order by `id` asc limit 0,2"
+	if (*queries)[1] != want {
+		t.Fatalf("sql = %s", (*queries)[1])
+	}
+	count := 0
+	err = slt.ChunkFrom(int64(2)).Chunk(2, func(list []H) error {
+		count++
+		return ErrStop
+	})
+	if err != nil || count != 1 {
+		t.Fatalf("err = %v, count = %d", err, count)
+	}
+}
+
+func TestChunkMissingKey(t *testing.T) {
+	srv, _ := chunkServer(5)
+	slt := NewSelector(newFakeDB(srv), "@pf_user").ChunkKey("uid")
+	called := false
+	err := slt.Chunk(2, func([]H) error {
+		called = true
+		return nil
+	})
+	if err == nil || called {
+		t.Fatalf("err = %v, called = %v", err, called)
+	}
+	if err = slt.ChunkParallel(2, 2, func([]H) error { return nil }); err == nil {
+		t.Fatal("missing key should be rejected")
+	}
+}
+
+func TestChunkParallel(t *testing.T) {
+	srv, _ := chunkServer(7)
+	slt := NewSelector(newFakeDB(srv), "@pf_user")
+	var mutex sync.Mutex
+	seen := make(map[any]bool)
+	var last ChunkState
+	slt.OnChunk(func(state ChunkState) {
+		last = state
+	})
+	err := slt.ChunkParallel(3, 2, func(list []H) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, row := range list {
+			seen[row["id"]] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 7 || last.Chunks != 4 || last.Rows != 7 || last.LastKey != 7 {
+		t.Fatalf("seen = %d, state = %+v", len(seen), last)
+	}
+	fail := errors.New("fail")
+	err = slt.ChunkParallel(2, 2, func(list []H) error {
+		return fail
+	})
+	if err != fail {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"github.com/wj008/goyee/config"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PolicyRoundRobin = "round_robin"
+	PolicyWeighted   = "weighted"
+)
+
+type forcePrimaryKey struct{}
+
+// ForcePrimary 返回强制读主库的上下文，用于写入后立即读取
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+func isForcePrimary(ctx context.Context) bool {
+	force, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return force
+}
+
+type replica struct {
+	db      *sql.DB
+	weight  int
+	healthy atomic.Bool
+}
+
+type cluster struct {
+	mutex    sync.RWMutex
+	replicas []*replica
+	policy   string
+	counter  atomic.Uint64
+	stop     chan struct{}
+}
+
+func newCluster(policy string) *cluster {
+	if policy != PolicyWeighted {
+		policy = PolicyRoundRobin
+	}
+	return &cluster{
+		replicas: make([]*replica, 0),
+		policy:   policy,
+	}
+}
+
+// pick 按策略选择一个健康的从库，没有可用从库时返回 nil
+func (c *cluster) pick() *sql.DB {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	healthy := make([]*replica, 0, len(c.replicas))
+	total := 0
+	for _, r := range c.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+			total += r.weight
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	n := c.counter.Add(1) - 1
+	if c.policy != PolicyWeighted || total <= 0 {
+		return healthy[n%uint64(len(healthy))].db
+	}
+	point := int(n % uint64(total))
+	for _, r := range healthy {
+		if point < r.weight {
+			return r.db
+		}
+		point -= r.weight
+	}
+	return healthy[0].db
+}
+
+// check 检查从库状态，失败的从库会被剔除直到恢复
+func (c *cluster) check() {
+	c.mutex.RLock()
+	replicas := c.replicas
+	c.mutex.RUnlock()
+	for _, r := range replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err := r.db.PingContext(ctx)
+		cancel()
+		if err != nil {
+			if r.healthy.Swap(false) {
+				log.Println("从库连接失败，已剔除", err.Error())
+			}
+			continue
+		}
+		r.healthy.Store(true)
+	}
+}
+
+func (c *cluster) startCheck(interval time.Duration) {
+	if interval <= 0 || c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.check()
+			}
+		}
+	}(c.stop)
+}
+
+func (c *cluster) close() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, r := range c.replicas {
+		r.db.Close()
+	}
+	c.replicas = nil
+}
+
+// reader 获取用于读取的连接池
+func (db *DB) reader(ctx context.Context) *sql.DB {
+	if db.cluster == nil || isForcePrimary(ctx) {
+		return db.DB
+	}
+	if r := db.cluster.pick(); r != nil {
+		return r
+	}
+	return db.DB
+}
+
+// AddReplica 添加从库，weight 为加权策略下的权重
+func (db *DB) AddReplica(replicaDb *sql.DB, weight int) *DB {
+	if db.cluster == nil {
+		db.cluster = newCluster(PolicyRoundRobin)
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	r := &replica{db: replicaDb, weight: weight}
+	r.healthy.Store(true)
+	db.cluster.mutex.Lock()
+	db.cluster.replicas = append(db.cluster.replicas, r)
+	db.cluster.mutex.Unlock()
+	return db
+}
+
+// SetReplicaPolicy 设置从库选择策略 PolicyRoundRobin 或 PolicyWeighted
+func (db *DB) SetReplicaPolicy(policy string) *DB {
+	if db.cluster == nil {
+		db.cluster = newCluster(policy)
+		return db
+	}
+	if policy != PolicyWeighted {
+		policy = PolicyRoundRobin
+	}
+	db.cluster.mutex.Lock()
+	db.cluster.policy = policy
+	db.cluster.mutex.Unlock()
+	return db
+}
+
+// StartHealthCheck 定时检查从库状态
+func (db *DB) StartHealthCheck(interval time.Duration) *DB {
+	if db.cluster != nil {
+		db.cluster.startCheck(interval)
+	}
+	return db
+}
+
+// Close 关闭主库和所有从库
+func (db *DB) Close() error {
+	if db.stmts != nil {
+		db.stmts.close()
+	}
+	if db.cluster != nil {
+		db.cluster.close()
+	}
+	return db.DB.Close()
+}
+
+// openReplicas 按配置打开从库，配置项 db_replicas 为逗号分隔的 host:port 列表
+func openReplicas(db *DB, name string, dsn func(host string, port string) string) {
+	hosts := config.String(configKey(name, "replicas"), "")
+	if strings.TrimSpace(hosts) == "" {
+		return
+	}
+	weights := strings.Split(config.String(configKey(name, "replica_weights"), ""), ",")
+	db.SetReplicaPolicy(config.String(configKey(name, "replica_policy"), PolicyRoundRobin))
+	for i, item := range strings.Split(hosts, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		host, port, found := strings.Cut(item, ":")
+		if !found {
+			port = "3306"
+		}
+		replicaDb, err := sql.Open("mysql", dsn(host, port))
+		if err != nil {
+			log.Println("打开从库失败", item, err.Error())
+			continue
+		}
+		replicaDb.SetConnMaxLifetime(time.Duration(cfgInt(name, "max_lifetime", 100)) * time.Second)
+		replicaDb.SetMaxOpenConns(cfgInt(name, "pool_size", 1))
+		replicaDb.SetMaxIdleConns(cfgInt(name, "max_idle", 1))
+		weight := 1
+		if i < len(weights) {
+			if w, err := strconv.Atoi(strings.TrimSpace(weights[i])); err == nil {
+				weight = w
+			}
+		}
+		db.AddReplica(replicaDb, weight)
+	}
+	if db.cluster != nil {
+		db.cluster.check()
+		db.StartHealthCheck(time.Duration(config.Int(configKey(name, "replica_check"), 10)) * time.Second)
+	}
+}
@@ -0,0 +1,85 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func testReplicas(t *testing.T, c *cluster, weights ...int) []*sql.DB {
+	list := make([]*sql.DB, 0, len(weights))
+	for _, weight := range weights {
+		db, err := sql.Open("mysql", "root@tcp(127.0.0.1:1)/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &replica{db: db, weight: weight}
+		r.healthy.Store(true)
+		c.replicas = append(c.replicas, r)
+		list = append(list, db)
+	}
+	t.Cleanup(c.close)
+	return list
+}
+
+func countPicks(c *cluster, n int) map[*sql.DB]int {
+	counts := make(map[*sql.DB]int)
+	for i := 0; i < n; i++ {
+		counts[c.pick()]++
+	}
+	return counts
+}
+
+func TestPickRoundRobin(t *testing.T) {
+	c := newCluster(PolicyRoundRobin)
+	if c.pick() != nil {
+		t.Fatal("empty cluster should pick nil")
+	}
+	list := testReplicas(t, c, 1, 5, 1)
+	counts := countPicks(c, 9)
+	for _, db := range list {
+		if counts[db] != 3 {
+			t.Fatalf("counts = %v", counts)
+		}
+	}
+	c.replicas[1].healthy.Store(false)
+	counts = countPicks(c, 8)
+	if counts[list[1]] != 0 || counts[list[0]] != 4 || counts[list[2]] != 4 {
+		t.Fatalf("unhealthy replica should be skipped, counts = %v", counts)
+	}
+	for _, r := range c.replicas {
+		r.healthy.Store(false)
+	}
+	if c.pick() != nil {
+		t.Fatal("no healthy replica should pick nil")
+	}
+}
+
+func TestPickWeighted(t *testing.T) {
+	c := newCluster(PolicyWeighted)
+	list := testReplicas(t, c, 1, 3)
+	counts := countPicks(c, 40)
+	if counts[list[0]] != 10 || counts[list[1]] != 30 {
+		t.Fatalf("counts = %v", counts)
+	}
+	c.replicas[1].healthy.Store(false)
+	counts = countPicks(c, 4)
+	if counts[list[0]] != 4 {
+		t.Fatalf("counts = %v", counts)
+	}
+}
+
+func TestCheckEjectsReplica(t *testing.T) {
+	c := newCluster(PolicyRoundRobin)
+	testReplicas(t, c, 1)
+	c.check()
+	if c.replicas[0].healthy.Load() || c.pick() != nil {
+		t.Fatal("unreachable replica should be ejected")
+	}
+	db := &DB{cluster: c}
+	db.DB, _ = sql.Open("mysql", "root@tcp(127.0.0.1:1)/test")
+	defer db.DB.Close()
+	if db.reader(context.Background()) != db.DB {
+		t.Fatal("reader should fall back to primary")
+	}
+}
@@ -1,6 +1,7 @@
 package dbs
 
 import (
+	"reflect"
 	"regexp"
 	"strings"
 )
@@ -16,6 +17,9 @@ const (
 type Condition struct {
 	typ   string
 	items []*Frame
+	err   error    // 子查询构建时的错误
+	expr  string   // 第一个不是合法标识符的字段，严格模式下执行时拒绝
+	tags  []string // 子查询中用到的表，用于缓存失效
 }
 
 /*
@@ -38,12 +42,25 @@ func (cond *Condition) Where(sql string, args ...any) *Condition {
 	if sql == "" {
 		return cond
 	}
+	cond.setErr(selectorErr(args...))
+	cond.tags = append(cond.tags, selectorTags(args...)...)
+	sql, args = expandArgs(sql, args)
 	item := NewFrame(sql, "where", args...)
 	cond.items = append(cond.items, item)
 	return cond
 }
 
+// setErr 记录第一个错误
+func (cond *Condition) setErr(err error) {
+	if cond.err == nil {
+		cond.err = err
+	}
+}
+
 func (cond *Condition) WhereC(c *Condition) *Condition {
+	cond.setErr(c.err)
+	cond.setExpr(c.expr)
+	cond.tags = append(cond.tags, c.tags...)
 	frame := c.GetFrame()
 	if frame.Sql == "" {
 		return cond
@@ -64,68 +81,68 @@ func (cond *Condition) WhereC(c *Condition) *Condition {
 	return cond
 }
 
-func (cond *Condition) Search(sql string, value any, typ SearchType) *Condition {
-	isA := false
+// skipSearch 按搜索类型判断是否忽略该值，isA 表示值为 []any 列表
+func skipSearch(value any, typ SearchType) (skip bool, isA bool) {
 	if typ == WithoutZero {
 		switch value.(type) {
 		case nil:
-			return cond
+			return true, false
 		case string:
 			if value == "" {
-				return cond
+				return true, false
 			}
 		case int64:
 			if value.(int64) == 0 {
-				return cond
+				return true, false
 			}
 		case uint64:
 			if value.(uint64) == 0 {
-				return cond
+				return true, false
 			}
 		case int32:
 			if value.(int32) == 0 {
-				return cond
+				return true, false
 			}
 		case uint32:
 			if value.(uint32) == 0 {
-				return cond
+				return true, false
 			}
 		case int:
 			if value.(int) == 0 {
-				return cond
+				return true, false
 			}
 		case uint:
 			if value.(uint) == 0 {
-				return cond
+				return true, false
 			}
 		case float64:
 			if value.(float64) == 0 {
-				return cond
+				return true, false
 			}
 		case float32:
 			if value.(float32) == 0 {
-				return cond
+				return true, false
 			}
 		case bool:
 			if value.(bool) == false {
-				return cond
+				return true, false
 			}
 		case []any:
 			isA = true
 			if len(value.([]any)) == 0 {
-				return cond
+				return true, false
 			}
 			break
 		default:
-			return cond
+			return true, false
 		}
 	} else if typ == WithoutEmpty {
 		switch value.(type) {
 		case nil:
-			return cond
+			return true, false
 		case string:
 			if value.(string) == "" {
-				return cond
+				return true, false
 			}
 			break
 		case int64, uint64, int32, uint32, int, uint, float64, float32, bool:
@@ -133,28 +150,36 @@ func (cond *Condition) Search(sql string, value any, typ SearchType) *Condition
 		case []any:
 			isA = true
 			if len(value.([]any)) == 0 {
-				return cond
+				return true, false
 			}
 			break
 		default:
-			return cond
+			return true, false
 		}
 	} else if typ == WithoutNil {
 		switch value.(type) {
 		case nil:
-			return cond
+			return true, false
 		case string, int64, uint64, int32, uint32, int, uint, float64, float32, bool:
 			break
 		case []any:
 			isA = true
 			if len(value.([]any)) == 0 {
-				return cond
+				return true, false
 			}
 			break
 		default:
-			return cond
+			return true, false
 		}
 	}
+	return false, isA
+}
+
+func (cond *Condition) Search(sql string, value any, typ SearchType) *Condition {
+	skip, isA := skipSearch(value, typ)
+	if skip {
+		return cond
+	}
 	if isA {
 		if strings.Count(sql, "[?]") != 1 {
 			return cond
@@ -196,7 +221,204 @@ func (cond *Condition) GetFrame() *Frame {
 	}
 }
 
+// appendGroup 将原有条件整体加上括号后追加 item，避免原有的 or 条件影响 item
+func appendGroup(items []*Frame, item *Frame) []*Frame {
+	if len(items) == 0 {
+		return []*Frame{item}
+	}
+	frame := (&Condition{items: items}).GetFrame()
+	return []*Frame{NewFrame("("+frame.Sql+")", "where", frame.Args...), item}
+}
+
 func (cond *Condition) Empty() *Condition {
 	cond.items = make([]*Frame, 0)
 	return cond
 }
+
+// setExpr 记录第一个不是合法标识符的字段
+func (cond *Condition) setExpr(column string) {
+	if cond.expr == "" {
+		cond.expr = column
+	}
+}
+
+// quoteColumn 合法的字段名使用 QuoteIdent 转义，表达式原样返回并记录，严格模式下执行时会被拒绝
+func (cond *Condition) quoteColumn(column string) string {
+	column = strings.TrimSpace(column)
+	if !ValidIdent(column) {
+		cond.setExpr(column)
+		return column
+	}
+	return QuoteIdent(column)
+}
+
+// EscapeLike 转义 like 语句中的通配符
+func EscapeLike(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "%", `\%`)
+	return strings.ReplaceAll(value, "_", `\_`)
+}
+
+func (cond *Condition) compare(column string, op string, value any) *Condition {
+	return cond.Where(cond.quoteColumn(column)+" "+op+" ?", value)
+}
+
+// Eq 等于，value 为 nil 时生成 is null
+func (cond *Condition) Eq(column string, value any) *Condition {
+	if value == nil {
+		return cond.IsNull(column)
+	}
+	return cond.compare(column, "=", value)
+}
+
+// Ne 不等于，value 为 nil 时生成 is not null
+func (cond *Condition) Ne(column string, value any) *Condition {
+	if value == nil {
+		return cond.IsNotNull(column)
+	}
+	return cond.compare(column, "<>", value)
+}
+
+// Gt 大于
+func (cond *Condition) Gt(column string, value any) *Condition {
+	return cond.compare(column, ">", value)
+}
+
+// Gte 大于等于
+func (cond *Condition) Gte(column string, value any) *Condition {
+	return cond.compare(column, ">=", value)
+}
+
+// Lt 小于
+func (cond *Condition) Lt(column string, value any) *Condition {
+	return cond.compare(column, "<", value)
+}
+
+// Lte 小于等于
+func (cond *Condition) Lte(column string, value any) *Condition {
+	return cond.compare(column, "<=", value)
+}
+
+// toSlice 将切片或数组转为 []any，其他值作为单个元素
+func toSlice(values any) []any {
+	if items, ok := values.([]any); ok {
+		return items
+	}
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []any{values}
+	}
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []any{values}
+	}
+	items := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items
+}
+
+func (cond *Condition) in(column string, op string, values any) *Condition {
+	switch values.(type) {
+	case *Selector, *Frame:
+		return cond.Where(cond.quoteColumn(column)+" "+op+" ?", values)
+	}
+	items := toSlice(values)
+	if len(items) == 0 {
+		if op == "in" {
+			return cond.Where("1=0")
+		}
+		return cond
+	}
+	ques := strings.TrimSuffix(strings.Repeat("?,", len(items)), ",")
+	return cond.Where(cond.quoteColumn(column)+" "+op+" ("+ques+")", items...)
+}
+
+// In 在列表中，values 为空时不匹配任何数据
+func (cond *Condition) In(column string, values any) *Condition {
+	return cond.in(column, "in", values)
+}
+
+// NotIn 不在列表中，values 为空时忽略该条件
+func (cond *Condition) NotIn(column string, values any) *Condition {
+	return cond.in(column, "not in", values)
+}
+
+// Between 在区间内，包含两端
+func (cond *Condition) Between(column string, start any, end any) *Condition {
+	return cond.Where(cond.quoteColumn(column)+" between ? and ?", start, end)
+}
+
+// Like 包含，value 中的通配符会被转义
+func (cond *Condition) Like(column string, value string) *Condition {
+	return cond.compare(column, "like", "%"+EscapeLike(value)+"%")
+}
+
+// StartsWith 以 value 开头
+func (cond *Condition) StartsWith(column string, value string) *Condition {
+	return cond.compare(column, "like", EscapeLike(value)+"%")
+}
+
+// EndsWith 以 value 结尾
+func (cond *Condition) EndsWith(column string, value string) *Condition {
+	return cond.compare(column, "like", "%"+EscapeLike(value))
+}
+
+// IsNull 为 NULL
+func (cond *Condition) IsNull(column string) *Condition {
+	return cond.Where(cond.quoteColumn(column) + " is null")
+}
+
+// IsNotNull 不为 NULL
+func (cond *Condition) IsNotNull(column string) *Condition {
+	return cond.Where(cond.quoteColumn(column) + " is not null")
+}
+
+// Exists 子查询存在数据
+func (cond *Condition) Exists(sub *Selector) *Condition {
+	cond.setErr(sub.Err())
+	cond.tags = append(cond.tags, sub.cacheTags()...)
+	frame := sub.BuildSql(false)
+	return cond.Where("exists ("+frame.Sql+")", frame.Args...)
+}
+
+// NotExists 子查询不存在数据
+func (cond *Condition) NotExists(sub *Selector) *Condition {
+	cond.setErr(sub.Err())
+	cond.tags = append(cond.tags, sub.cacheTags()...)
+	frame := sub.BuildSql(false)
+	return cond.Where("not exists ("+frame.Sql+")", frame.Args...)
+}
+
+// And 以 and 连接一组条件
+func (cond *Condition) And(c *Condition) *Condition {
+	return cond.WhereC(c)
+}
+
+// Or 以 or 连接一组条件，已有条件和 c 分别加上括号，合并后作为一个整体与后续条件以 and 连接
+func (cond *Condition) Or(c *Condition) *Condition {
+	cond.setErr(c.err)
+	cond.setExpr(c.expr)
+	cond.tags = append(cond.tags, c.tags...)
+	frame := c.GetFrame()
+	if frame.Sql == "" {
+		return cond
+	}
+	if len(cond.items) == 0 {
+		cond.items = append(cond.items, NewFrame("("+frame.Sql+")", "where", frame.Args...))
+		return cond
+	}
+	current := cond.GetFrame()
+	args := append(append(make([]any, 0, len(current.Args)+len(frame.Args)), current.Args...), frame.Args...)
+	cond.items = []*Frame{NewFrame("(("+current.Sql+") or ("+frame.Sql+"))", "where", args...)}
+	return cond
+}
+
+// OrWhere 以 or 连接查询条件
+func (cond *Condition) OrWhere(sql string, args ...any) *Condition {
+	sql = strings.TrimSpace(sql)
+	if sql == "" {
+		return cond
+	}
+	return cond.Where("or "+sql, args...)
+}
@@ -0,0 +1,112 @@
+package dbs
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestConditionOperators(t *testing.T) {
+	cond := NewCondition().
+		Eq("a.status", 1).
+		In("type", []int{1, 2}).
+		Between("age", 18, 30).
+		Like("name", "50%_off").
+		IsNull("deleted_at").
+		Or(NewCondition().Gt("score", 90).Ne("level", nil))
+	frame := cond.GetFrame()
+	want := "((`a`.`status` = ? and `type` in (?,?) and `age` between ? and ? and `name` like ? and `deleted_at` is null) or (`score` > ? and `level` is not null))"
+	if frame.Sql != want {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	args := []any{1, 1, 2, 18, 30, `%50\%\_off%`, 90}
+	if !reflect.DeepEqual(frame.Args, args) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+}
+
+func TestConditionOrGroup(t *testing.T) {
+	cond := NewCondition().Eq("a", 1).Or(NewCondition().Eq("b", 2)).Eq("c", 3)
+	frame := cond.GetFrame()
+	want := "((`a` = ?) or (`b` = ?)) and `c` = ?"
+	if frame.Sql != want || !reflect.DeepEqual(frame.Args, []any{1, 2, 3}) {
+		t.Fatalf("sql = %s %v", frame.Sql, frame.Args)
+	}
+	frame = NewCondition().Or(NewCondition().Eq("b", 2).Eq("d", 4)).GetFrame()
+	if frame.Sql != "(`b` = ? and `d` = ?)" {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+}
+
+func TestConditionEmptyIn(t *testing.T) {
+	frame := NewCondition().In("id", []int{}).NotIn("id", []string{}).GetFrame()
+	if frame.Sql != "1=0" || len(frame.Args) != 0 {
+		t.Fatalf("sql = %s %v", frame.Sql, frame.Args)
+	}
+}
+
+func TestConditionExists(t *testing.T) {
+	sub := NewSelector(nil, "@pf_order").Field("1")
+	sub.Where("order.user_id=user.id").Gt("amount", 100)
+	frame := NewCondition().Eq("state", 1).Exists(sub).GetFrame()
+	want := "`state` = ? and exists (select 1 from `@pf_order` where order.user_id=user.id and `amount` > ?)"
+	if frame.Sql != want || !reflect.DeepEqual(frame.Args, []any{1, 100}) {
+		t.Fatalf("sql = %s %v", frame.Sql, frame.Args)
+	}
+}
+
+func TestConditionFromValues(t *testing.T) {
+	values := url.Values{
+		"status":  {"1"},
+		"type":    {"1,2,x"},
+		"keyword": {"go_"},
+		"page":    {"3"},
+		"min_age": {""},
+		"uid":     {"abc"},
+	}
+	spec := FilterSpec{
+		"status":  {Type: "int", Search: WithoutNil},
+		"type":    {Op: "in", Type: "int"},
+		"keyword": {Column: "name", Op: "like"},
+		"min_age": {Column: "age", Op: "gte", Type: "int"},
+		"uid":     {Column: "user_id", Type: "int"},
+	}
+	cond, err := NewCondition().FromValues(values, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := cond.GetFrame()
+	want := "`name` like ? and `status` = ? and `type` in (?,?)"
+	if frame.Sql != want {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if !reflect.DeepEqual(frame.Args, []any{`%go\_%`, 1, 1, 2}) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+}
+
+func TestConditionFilterErrors(t *testing.T) {
+	cond := NewCondition().Eq("state", 1)
+	if _, err := cond.FromMap(H{"age": "1"}, FilterSpec{"age": {Op: "greater"}}); err == nil {
+		t.Fatal("unknown op should be rejected")
+	}
+	if _, err := cond.FromMap(H{"age": "1"}, FilterSpec{"age": {Type: "number"}}); err == nil {
+		t.Fatal("unknown type should be rejected")
+	}
+	spec := FilterSpec{"name": {Op: "LIKE"}, "age": {Op: "between", Type: "Int"}}
+	if _, err := cond.FromMap(H{"name": "go", "age": "1,2,3"}, spec); err == nil {
+		t.Fatal("between with 3 values should be rejected")
+	}
+	if frame := cond.GetFrame(); frame.Sql != "`state` = ?" {
+		t.Fatalf("failed filter should not add conditions: %s", frame.Sql)
+	}
+	if _, err := cond.FromMap(H{"name": "go", "age": "18,30"}, spec); err != nil {
+		t.Fatal(err)
+	}
+	if frame := cond.GetFrame(); frame.Sql != "`state` = ? and `age` between ? and ? and `name` like ?" {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if args := cond.GetFrame().Args; args[1] != 18 || args[2] != 30 {
+		t.Fatalf("mixed case type should be coerced: %#v", args)
+	}
+}
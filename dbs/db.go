@@ -5,10 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/wj008/goyee/config"
-	"log"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
 )
 
 type H = map[string]any
@@ -16,15 +15,32 @@ type A = []any
 
 type DB struct {
 	*sql.DB
-	prefix string
+	name    string
+	prefix  string
+	cluster *cluster
+	hooks   []Hook
+	metrics *Metrics
+	mode    FetchMode
+	strict  bool
+	stmts   *stmtCache
+	packet  atomic.Int64 // 缓存的 max_allowed_packet
+
+	schemaMode SchemaMode
+	schema     schemaCache
+
+	cacheMutex sync.Mutex
+	cache      Cache
+	cacheGens  map[string]uint64 // 各表的失效版本
+	flight     flightGroup
 }
 type Tx struct {
 	*sql.Tx
-	prefix string
+	db        *DB
+	prefix    string
+	savepoint int
+	hooks     []func()
 }
 
-var mainDb *DB
-
 func Raw(sql string, args ...any) *Frame {
 	return &Frame{
 		Sql:  sql,
@@ -33,36 +49,6 @@ func Raw(sql string, args ...any) *Frame {
 	}
 }
 
-// Db 获取主数据库
-func Db() (*DB, error) {
-	if mainDb != nil {
-		return mainDb, nil
-	}
-	userName := config.String("db_username", "root")
-	password := config.String("db_password", "")
-	host := config.String("db_host", "127.0.0.1")
-	port := config.String("db_port", "3306")
-	dbName := config.String("db_dbname", "test")
-	charset := config.String("db_charset", "utf8")
-	maxLifetime := config.Int("db_max_lifetime", 100)
-	poolSize := config.Int("db_pool_size", 1)
-	prefix := config.String("db_prefix", "")
-	path := strings.Join([]string{userName, ":", password, "@tcp(", host, ":", port, ")/", dbName, "?charset=", charset, "&parseTime=True"}, "")
-	db, _ := sql.Open("mysql", path)
-	//设置数据库超时时间
-	db.SetConnMaxLifetime(time.Duration(maxLifetime) * time.Second)
-	db.SetMaxOpenConns(poolSize)
-	//设置上数据库最大闲置连接数
-	db.SetMaxIdleConns(1)
-	//验证连接
-	if err := db.Ping(); err != nil {
-		log.Println("打开数据库失败", err.Error())
-		return nil, err
-	}
-	mainDb = &DB{DB: db, prefix: prefix}
-	return mainDb, nil
-}
-
 // TxBegin 事务开始
 func TxBegin() (*Tx, error) {
 	db, err := Db()
@@ -74,61 +60,71 @@ func TxBegin() (*Tx, error) {
 
 // Exec 执行代码
 func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
-	query = strings.Replace(query, "@pf_", db.prefix, -1)
-	return db.DB.Exec(query, args...)
+	return db.ExecContext(context.Background(), query, args...)
 }
 
 // ExecContext 执行代码
 func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	query = strings.Replace(query, "@pf_", db.prefix, -1)
-	return db.DB.ExecContext(ctx, query, args...)
+	return withHooks(ctx, db.hooks, "exec", query, args, func(ctx context.Context) (sql.Result, error) {
+		if stmt, release := db.stmt(ctx, db.DB, query, args); stmt != nil {
+			defer release()
+			return stmt.ExecContext(ctx, args...)
+		}
+		return db.DB.ExecContext(ctx, query, args...)
+	})
 }
 
 // Query 查询多行
 func (db *DB) Query(query string, args ...any) ([]H, error) {
-	query = strings.Replace(query, "@pf_", db.prefix, -1)
-	rows, err := db.DB.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	return fetch(rows)
+	return db.QueryContext(context.Background(), query, args...)
 }
 
-// QueryContext 带有上下文查询多行
+// QueryContext 带有上下文查询多行，钩子记录的耗时包含读取结果的时间
 func (db *DB) QueryContext(ctx context.Context, query string, args ...any) ([]H, error) {
 	query = strings.Replace(query, "@pf_", db.prefix, -1)
-	rows, err := db.DB.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+	return withHooks(ctx, db.hooks, "query", query, args, func(ctx context.Context) ([]H, error) {
+		rows, err := db.queryRows(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return fetch(rows, db.fetchModeOf(ctx))
+	})
+}
+
+// QueryRows 查询并返回原始结果集，调用方负责关闭
+func (db *DB) QueryRows(query string, args ...any) (*sql.Rows, error) {
+	return db.QueryRowsContext(context.Background(), query, args...)
+}
+
+// QueryRowsContext 带有上下文查询并返回原始结果集，调用方负责关闭
+// 配置了从库时查询会路由到从库，使用 ForcePrimary 可强制读主库
+// 钩子记录的耗时只包含执行时间，不包含调用方读取结果的时间
+func (db *DB) QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = strings.Replace(query, "@pf_", db.prefix, -1)
+	return withHooks(ctx, db.hooks, "query", query, args, func(ctx context.Context) (*sql.Rows, error) {
+		return db.queryRows(ctx, query, args)
+	})
+}
+
+// queryRows 执行已替换前缀的查询，不调用钩子
+func (db *DB) queryRows(ctx context.Context, query string, args []any) (*sql.Rows, error) {
+	conn := db.reader(ctx)
+	if stmt, release := db.stmt(ctx, conn, query, args); stmt != nil {
+		defer release()
+		return stmt.QueryContext(ctx, args...)
 	}
-	return fetch(rows)
+	return conn.QueryContext(ctx, query, args...)
 }
 
 // QueryRow 查询1行
 func (db *DB) QueryRow(query string, args ...any) (H, error) {
-	query = strings.Replace(query, "@pf_", db.prefix, -1)
-	rows, err := db.DB.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	list, err := fetch(rows)
-	if err != nil {
-		return nil, err
-	}
-	if len(list) > 0 {
-		return list[0], nil
-	}
-	return nil, nil
+	return db.QueryRowContext(context.Background(), query, args...)
 }
 
 // QueryRowContext 带有上下文查询1行
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) (H, error) {
-	query = strings.Replace(query, "@pf_", db.prefix, -1)
-	rows, err := db.DB.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	list, err := fetch(rows)
+	list, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -140,207 +136,218 @@ func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) (H
 
 // Insert 插入数据集
 func (db *DB) Insert(table string, data H) (sql.Result, error) {
-	var names []string
-	var temps []string
-	var values []any
-	for key, value := range data {
-		names = append(names, "`"+key+"`")
-		switch value.(type) {
-		case *Frame:
-			temps = append(temps, value.(*Frame).Format())
-			break
-		default:
-			temps = append(temps, "?")
-			values = append(values, value)
-			break
-		}
+	return db.InsertContext(context.Background(), table, data)
+}
+
+// InsertContext 带有上下文插入数据集
+func (db *DB) InsertContext(ctx context.Context, table string, data H) (sql.Result, error) {
+	data, err := db.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, err
 	}
-	if len(names) == 0 {
-		return nil, errors.New("插入失败，没有相应的数据")
+	frame, err := buildInsert("insert", table, data)
+	if err != nil {
+		return nil, err
 	}
-	sql := "insert into `" + table + "` (" + strings.Join(names, ",") + ") values (" + strings.Join(temps, ",") + ")"
-	return db.Exec(sql, values...)
+	res, err := db.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	db.invalidate(table)
+	return res, nil
 }
 
 // InsertAndGetLastId 添加并返回最后的ID
 func (db *DB) InsertAndGetLastId(table string, data H) (sql.Result, int, error) {
-	tx, err := db.Begin()
+	return db.InsertAndGetLastIdContext(context.Background(), table, data)
+}
+
+// InsertAndGetLastIdContext 带有上下文添加并返回最后的ID
+func (db *DB) InsertAndGetLastIdContext(ctx context.Context, table string, data H) (sql.Result, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, 0, err
 	}
-	res, lastId, err := tx.InsertAndGetLastId(table, data)
+	res, lastId, err := tx.InsertAndGetLastIdContext(ctx, table, data)
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
 	}
-	tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return nil, 0, err
+	}
 	return res, lastId, nil
 }
 
 // Replace 替换数据集
 func (db *DB) Replace(table string, data H) (sql.Result, error) {
-	var names []string
-	var temps []string
-	var values []any
-	for key, value := range data {
-		names = append(names, "`"+key+"`")
-		switch value.(type) {
-		case *Frame:
-			temps = append(temps, value.(*Frame).Format())
-			break
-		default:
-			temps = append(temps, "?")
-			values = append(values, value)
-			break
-		}
+	return db.ReplaceContext(context.Background(), table, data)
+}
+
+// ReplaceContext 带有上下文替换数据集
+func (db *DB) ReplaceContext(ctx context.Context, table string, data H) (sql.Result, error) {
+	data, err := db.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, err
 	}
-	if len(names) == 0 {
-		return nil, errors.New("插入失败，没有相应的数据")
+	frame, err := buildInsert("replace", table, data)
+	if err != nil {
+		return nil, err
 	}
-	sql := "replace into `" + table + "` (" + strings.Join(names, ",") + ") values (" + strings.Join(temps, ",") + ")"
-	return db.Exec(sql, values...)
+	res, err := db.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	db.invalidate(table)
+	return res, nil
 }
 
 // Update 更新数据集合
 func (db *DB) Update(table string, data H, where any, args ...any) (sql.Result, error) {
-	var names []string
-	var values []any
-	var temps []any
-	whereSql := ""
-	switch where.(type) {
-	case int, int64, int32, uint32, uint64:
-		temps = append(temps, where)
-		whereSql = "id=?"
-		break
-	default:
-		whereSql = where.(string)
-		break
-	}
-	if len(whereSql) == 0 {
-		return nil, errors.New("更新，缺少查询语句")
+	return db.UpdateContext(context.Background(), table, data, where, args...)
+}
+
+// UpdateContext 带有上下文更新数据集合
+func (db *DB) UpdateContext(ctx context.Context, table string, data H, where any, args ...any) (sql.Result, error) {
+	data, err := db.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, err
 	}
-	for key, value := range data {
-		switch value.(type) {
-		case *Frame:
-			names = append(names, "`"+key+"`="+value.(*Frame).Format())
-			break
-		default:
-			names = append(names, "`"+key+"`=?")
-			values = append(values, value)
-			break
-		}
+	frame, err := buildUpdate(table, data, where, args)
+	if err != nil {
+		return nil, err
 	}
-	if len(names) == 0 {
-		return nil, errors.New("更新，没有相应的数据")
+	res, err := db.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
 	}
-	values = append(values, temps...)
-	values = append(values, args...)
-	sql := "update `" + table + "` set " + strings.Join(names, ",") + " where " + whereSql
-	return db.Exec(sql, values...)
+	db.invalidate(table)
+	return res, nil
 }
 
 // Delete 删除数据
 func (db *DB) Delete(table string, where any, args ...any) (sql.Result, error) {
-	var temps []any
-	whereSql := ""
-	switch where.(type) {
-	case int, int64, int32, uint32, uint64:
-		temps = append(temps, where)
-		whereSql = "id=?"
-		break
-	default:
-		whereSql = where.(string)
-		break
+	return db.DeleteContext(context.Background(), table, where, args...)
+}
+
+// DeleteContext 带有上下文删除数据
+func (db *DB) DeleteContext(ctx context.Context, table string, where any, args ...any) (sql.Result, error) {
+	if err := db.checkWrite(table, nil); err != nil {
+		return nil, err
 	}
-	if len(whereSql) == 0 {
-		return nil, errors.New("更新，缺少查询语句")
+	frame, err := buildDelete(table, where, args)
+	if err != nil {
+		return nil, err
 	}
-	temps = append(temps, args...)
-	sql := "delete from `" + table + "` where " + whereSql
-	return db.Exec(sql, temps...)
+	res, err := db.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	db.invalidate(table)
+	return res, nil
 }
 
 // Begin 开启事务
 func (db *DB) Begin() (*Tx, error) {
-	tx, err := db.DB.Begin()
+	return db.BeginTx(context.Background(), nil)
+}
+
+// BeginTx 带有上下文开启事务
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	ntx := &Tx{Tx: tx}
+	ntx := &Tx{Tx: tx, db: db}
 	ntx.prefix = db.prefix
 	return ntx, nil
 }
 
 // Transaction 开启事务
 func (db *DB) Transaction(fn func(*Tx) error) (err error) {
-	tx, err := db.Begin()
+	return db.TransactionContext(context.Background(), fn)
+}
+
+// TransactionContext 带有上下文开启事务，上下文取消时事务会被回滚
+// fn 发生异常时回滚事务后继续抛出，AfterCommit 注册的函数在提交后执行
+func (db *DB) TransactionContext(ctx context.Context, fn func(*Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return
+		return err
 	}
-	err = fn(tx)
+	err = tx.call(fn, func() {
+		tx.Rollback()
+	})
 	if err != nil {
 		tx.Rollback()
-		return
+		return err
 	}
-	tx.Commit()
-	return
+	return tx.Commit()
 }
 
 func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
-	query = strings.Replace(query, "@pf_", tx.prefix, -1)
-	return tx.Tx.Exec(query, args...)
+	return tx.ExecContext(context.Background(), query, args...)
 }
 
 func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	query = strings.Replace(query, "@pf_", tx.prefix, -1)
-	return tx.Tx.ExecContext(ctx, query, args...)
+	return withHooks(ctx, tx.db.hooks, "exec", query, args, func(ctx context.Context) (sql.Result, error) {
+		if stmt, release := tx.stmt(ctx, query, args); stmt != nil {
+			defer release()
+			return stmt.ExecContext(ctx, args...)
+		}
+		return tx.Tx.ExecContext(ctx, query, args...)
+	})
 }
 
 // Query 查询多行
 func (tx *Tx) Query(query string, args ...any) ([]H, error) {
-	query = strings.Replace(query, "@pf_", tx.prefix, -1)
-	rows, err := tx.Tx.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	return fetch(rows)
+	return tx.QueryContext(context.Background(), query, args...)
 }
 
-// QueryContext 查询多行
+// QueryContext 查询多行，钩子记录的耗时包含读取结果的时间
 func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) ([]H, error) {
 	query = strings.Replace(query, "@pf_", tx.prefix, -1)
-	rows, err := tx.Tx.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+	return withHooks(ctx, tx.db.hooks, "query", query, args, func(ctx context.Context) ([]H, error) {
+		rows, err := tx.queryRows(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return fetch(rows, tx.db.fetchModeOf(ctx))
+	})
+}
+
+// QueryRows 查询并返回原始结果集，调用方负责关闭
+func (tx *Tx) QueryRows(query string, args ...any) (*sql.Rows, error) {
+	return tx.QueryRowsContext(context.Background(), query, args...)
+}
+
+// QueryRowsContext 带有上下文查询并返回原始结果集，调用方负责关闭
+// 钩子记录的耗时只包含执行时间，不包含调用方读取结果的时间
+func (tx *Tx) QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = strings.Replace(query, "@pf_", tx.prefix, -1)
+	return withHooks(ctx, tx.db.hooks, "query", query, args, func(ctx context.Context) (*sql.Rows, error) {
+		return tx.queryRows(ctx, query, args)
+	})
+}
+
+// queryRows 执行已替换前缀的查询，不调用钩子
+func (tx *Tx) queryRows(ctx context.Context, query string, args []any) (*sql.Rows, error) {
+	if stmt, release := tx.stmt(ctx, query, args); stmt != nil {
+		defer release()
+		return stmt.QueryContext(ctx, args...)
 	}
-	return fetch(rows)
+	return tx.Tx.QueryContext(ctx, query, args...)
 }
 
 // QueryRow 查询1行
 func (tx *Tx) QueryRow(query string, args ...any) (H, error) {
-	query = strings.Replace(query, "@pf_", tx.prefix, -1)
-	rows, err := tx.Tx.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	list, err := fetch(rows)
-	if err != nil {
-		return nil, err
-	}
-	if len(list) > 0 {
-		return list[0], nil
-	}
-	return nil, nil
+	return tx.QueryRowContext(context.Background(), query, args...)
 }
 
 // QueryRowContext 查询1行
 func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) (H, error) {
-	query = strings.Replace(query, "@pf_", tx.prefix, -1)
-	rows, err := tx.Tx.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	list, err := fetch(rows)
+	list, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -352,11 +359,16 @@ func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) (H
 
 // LastInsertId 获得最后的Id
 func (tx *Tx) LastInsertId() (int, error) {
-	rows, err := tx.Tx.Query("SELECT LAST_INSERT_ID()")
+	return tx.LastInsertIdContext(context.Background())
+}
+
+// LastInsertIdContext 带有上下文获得最后的Id
+func (tx *Tx) LastInsertIdContext(ctx context.Context) (int, error) {
+	rows, err := tx.Tx.QueryContext(ctx, "SELECT LAST_INSERT_ID()")
 	if err != nil {
 		return 0, err
 	}
-	list, err := fetch(rows)
+	list, err := fetch(rows, FetchDefault)
 	if err != nil {
 		return 0, err
 	}
@@ -368,35 +380,39 @@ func (tx *Tx) LastInsertId() (int, error) {
 
 // Insert 插入数据集
 func (tx *Tx) Insert(table string, data H) (sql.Result, error) {
-	var names []string
-	var temps []string
-	var values []any
-	for key, value := range data {
-		names = append(names, "`"+key+"`")
-		switch value.(type) {
-		case *Frame:
-			temps = append(temps, value.(*Frame).Format())
-			break
-		default:
-			temps = append(temps, "?")
-			values = append(values, value)
-			break
-		}
+	return tx.InsertContext(context.Background(), table, data)
+}
+
+// InsertContext 带有上下文插入数据集
+func (tx *Tx) InsertContext(ctx context.Context, table string, data H) (sql.Result, error) {
+	data, err := tx.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, err
 	}
-	if len(names) == 0 {
-		return nil, errors.New("插入失败，没有相应的数据")
+	frame, err := buildInsert("insert", table, data)
+	if err != nil {
+		return nil, err
 	}
-	sql := "insert into `" + table + "` (" + strings.Join(names, ",") + ") values (" + strings.Join(temps, ",") + ")"
-	return tx.Exec(sql, values...)
+	res, err := tx.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	tx.invalidate(table)
+	return res, nil
 }
 
 // InsertAndGetLastId 插入数据集并且返回最后的ID
 func (tx *Tx) InsertAndGetLastId(table string, data H) (sql.Result, int, error) {
-	res, err := tx.Insert(table, data)
+	return tx.InsertAndGetLastIdContext(context.Background(), table, data)
+}
+
+// InsertAndGetLastIdContext 带有上下文插入数据集并且返回最后的ID
+func (tx *Tx) InsertAndGetLastIdContext(ctx context.Context, table string, data H) (sql.Result, int, error) {
+	res, err := tx.InsertContext(ctx, table, data)
 	if err != nil {
 		return nil, 0, err
 	}
-	lastId, err := tx.LastInsertId()
+	lastId, err := tx.LastInsertIdContext(ctx)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -405,11 +421,79 @@ func (tx *Tx) InsertAndGetLastId(table string, data H) (sql.Result, int, error)
 
 // Replace 替换数据集
 func (tx *Tx) Replace(table string, data H) (sql.Result, error) {
+	return tx.ReplaceContext(context.Background(), table, data)
+}
+
+// ReplaceContext 带有上下文替换数据集
+func (tx *Tx) ReplaceContext(ctx context.Context, table string, data H) (sql.Result, error) {
+	data, err := tx.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := buildInsert("replace", table, data)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	tx.invalidate(table)
+	return res, nil
+}
+
+// Update 更新数据集合
+func (tx *Tx) Update(table string, data H, where any, args ...any) (sql.Result, error) {
+	return tx.UpdateContext(context.Background(), table, data, where, args...)
+}
+
+// UpdateContext 带有上下文更新数据集合
+func (tx *Tx) UpdateContext(ctx context.Context, table string, data H, where any, args ...any) (sql.Result, error) {
+	data, err := tx.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := buildUpdate(table, data, where, args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	tx.invalidate(table)
+	return res, nil
+}
+
+// Delete 删除数据
+func (tx *Tx) Delete(table string, where any, args ...any) (sql.Result, error) {
+	return tx.DeleteContext(context.Background(), table, where, args...)
+}
+
+// DeleteContext 带有上下文删除数据
+func (tx *Tx) DeleteContext(ctx context.Context, table string, where any, args ...any) (sql.Result, error) {
+	if err := tx.db.checkWrite(table, nil); err != nil {
+		return nil, err
+	}
+	frame, err := buildDelete(table, where, args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	tx.invalidate(table)
+	return res, nil
+}
+
+// buildInsert 生成 insert 或 replace 语句
+func buildInsert(verb string, table string, data H) (*Frame, error) {
 	var names []string
 	var temps []string
 	var values []any
 	for key, value := range data {
-		names = append(names, "`"+key+"`")
+		names = append(names, QuoteIdent(key))
 		switch value.(type) {
 		case *Frame:
 			temps = append(temps, value.(*Frame).Format())
@@ -423,15 +507,12 @@ func (tx *Tx) Replace(table string, data H) (sql.Result, error) {
 	if len(names) == 0 {
 		return nil, errors.New("插入失败，没有相应的数据")
 	}
-	sql := "replace into `" + table + "` (" + strings.Join(names, ",") + ") values (" + strings.Join(temps, ",") + ")"
-	//log.Println(Sql)
-	return tx.Exec(sql, values...)
+	sql := verb + " into " + QuoteIdent(table) + " (" + strings.Join(names, ",") + ") values (" + strings.Join(temps, ",") + ")"
+	return NewFrame(sql, "sql", values...), nil
 }
 
-// Update 更新数据集合
-func (tx *Tx) Update(table string, data H, where any, args ...any) (sql.Result, error) {
-	var names []string
-	var values []any
+// buildWhere 解析ID或查询语句
+func buildWhere(where any, args []any) (string, []any, error) {
 	var temps []any
 	whereSql := ""
 	switch where.(type) {
@@ -440,131 +521,55 @@ func (tx *Tx) Update(table string, data H, where any, args ...any) (sql.Result,
 		whereSql = "id=?"
 		break
 	default:
-		whereSql = where.(string)
+		whereSql, _ = where.(string)
 		break
 	}
 	if len(whereSql) == 0 {
-		return nil, errors.New("更新，缺少查询语句")
+		return "", nil, errors.New("更新，缺少查询语句")
 	}
+	temps = append(temps, args...)
+	return whereSql, temps, nil
+}
+
+// buildSet 生成更新语句的赋值部分
+func buildSet(data H) ([]string, []any) {
+	var names []string
+	var values []any
 	for key, value := range data {
 		switch value.(type) {
 		case *Frame:
-			names = append(names, "`"+key+"`="+value.(*Frame).Format())
+			names = append(names, QuoteIdent(key)+"="+value.(*Frame).Format())
 			break
 		default:
-			names = append(names, "`"+key+"`=?")
+			names = append(names, QuoteIdent(key)+"=?")
 			values = append(values, value)
 			break
 		}
 	}
-	if len(names) == 0 {
-		return nil, errors.New("更新，没有相应的数据")
-	}
-	values = append(values, temps...)
-	values = append(values, args...)
-	sql := "update `" + table + "` set " + strings.Join(names, ",") + " where " + whereSql
-	return tx.Exec(sql, values...)
+	return names, values
 }
 
-// Delete 删除数据
-func (tx *Tx) Delete(table string, where any, args ...any) (sql.Result, error) {
-	var temps []any
-	whereSql := ""
-	switch where.(type) {
-	case int, int64, int32, uint32, uint64:
-		temps = append(temps, where)
-		whereSql = "id=?"
-		break
-	default:
-		whereSql = where.(string)
-		break
+// buildUpdate 生成更新语句
+func buildUpdate(table string, data H, where any, args []any) (*Frame, error) {
+	whereSql, temps, err := buildWhere(where, args)
+	if err != nil {
+		return nil, err
 	}
-	if len(whereSql) == 0 {
-		return nil, errors.New("更新，缺少查询语句")
+	names, values := buildSet(data)
+	if len(names) == 0 {
+		return nil, errors.New("更新，没有相应的数据")
 	}
-	temps = append(temps, args...)
-	sql := "delete from `" + table + "` where " + whereSql
-	return tx.Exec(sql, temps...)
+	values = append(values, temps...)
+	sql := "update " + QuoteIdent(table) + " set " + strings.Join(names, ",") + " where " + whereSql
+	return NewFrame(sql, "sql", values...), nil
 }
 
-// fetch 遍历数据
-func fetch(rows *sql.Rows) ([]H, error) {
-	defer rows.Close()
-	columns, err := rows.ColumnTypes()
-	columnLength := len(columns)
-	cache := make([]any, columnLength)
+// buildDelete 生成删除语句
+func buildDelete(table string, where any, args []any) (*Frame, error) {
+	whereSql, temps, err := buildWhere(where, args)
 	if err != nil {
 		return nil, err
 	}
-	for index, column := range columns {
-		typeName := column.DatabaseTypeName()
-		switch typeName {
-		case "INT", "TINYINT", "INTEGER", "BIGINT", "SMALLINT", "MEDIUMINT":
-			var a sql.NullInt64
-			cache[index] = &a
-			break
-		case "FLOAT", "DOUBLE", "DECIMAL":
-			var a sql.NullFloat64
-			cache[index] = &a
-			break
-		case "SMALLDATETIME", "DATETIME", "DATE":
-			var a sql.NullTime
-			cache[index] = &a
-			break
-		default:
-			var a sql.NullString
-			cache[index] = &a
-			break
-		}
-	}
-	list := make([]H, 0)
-	for rows.Next() {
-		err = rows.Scan(cache...)
-		if err != nil {
-			return nil, err
-		}
-		item := make(H)
-		for i, data := range cache {
-			key := columns[i].Name()
-			switch data.(type) {
-			case *sql.NullString:
-				a := *data.(*sql.NullString)
-				if a.Valid {
-					item[key] = a.String
-				} else {
-					item[key] = ""
-				}
-				break
-			case *sql.NullInt64:
-				a := *data.(*sql.NullInt64)
-				if a.Valid {
-					item[key] = int(a.Int64)
-				} else {
-					item[key] = 0
-				}
-				break
-			case *sql.NullTime:
-				a := *data.(*sql.NullTime)
-				if a.Valid {
-					item[key] = a.Time
-				} else {
-					item[key] = time.Time{}
-				}
-				break
-			case *sql.NullFloat64:
-				a := *data.(*sql.NullFloat64)
-				if a.Valid {
-					item[key] = a.Float64
-				} else {
-					item[key] = float64(0)
-				}
-				break
-			default:
-				item[key] = *data.(*any)
-				break
-			}
-		}
-		list = append(list, item)
-	}
-	return list, nil
+	sql := "delete from " + QuoteIdent(table) + " where " + whereSql
+	return NewFrame(sql, "sql", temps...), nil
 }
@@ -0,0 +1,221 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildUpsert(t *testing.T) {
+	data := H{"name": "goyee"}
+	frame, err := buildUpsert("user", data, []any{"name", Raw("cnt=cnt+1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "insert into `user` (`name`) values (?) on duplicate key update `name`=VALUES(`name`),cnt=cnt+1"
+	if frame.Sql != want {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	frame, err = buildUpsert("user", data, H{"name": "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(frame.Args, []any{"goyee", "other"}) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+	if _, err = buildUpsert("user", data, 1); err == nil {
+		t.Fatal("unsupported update type should be rejected")
+	}
+}
+
+func TestTypedValue(t *testing.T) {
+	kind, target := typedTarget("BIGINT")
+	*target.(*sql.NullString) = sql.NullString{String: "18446744073709551615", Valid: true}
+	if v := typedValue(kind, target); v != uint64(18446744073709551615) {
+		t.Fatalf("unsigned bigint = %#v", v)
+	}
+	kind, target = typedTarget("DECIMAL")
+	*target.(*sql.NullString) = sql.NullString{String: "12.30", Valid: true}
+	if v := typedValue(kind, target); v != "12.30" {
+		t.Fatalf("decimal = %#v", v)
+	}
+	kind, target = typedTarget("BIT")
+	*target.(*[]byte) = []byte{0x01, 0x02}
+	if v := typedValue(kind, target); v != uint64(258) {
+		t.Fatalf("bit = %#v", v)
+	}
+	kind, target = typedTarget("JSON")
+	if v := typedValue(kind, target); v != nil {
+		t.Fatalf("null json = %#v", v)
+	}
+	kind, target = typedTarget("INT")
+	if v := typedValue(kind, target); v != nil {
+		t.Fatalf("null int = %#v", v)
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	if s := QuoteIdent("a`b"); s != "`a``b`" {
+		t.Fatalf("quote = %s", s)
+	}
+	if s := QuoteIdent("db.@pf_user"); s != "`db`.`@pf_user`" {
+		t.Fatalf("quote = %s", s)
+	}
+	for _, name := range []string{"id", "@pf_user", "u.name", "db.@pf_user"} {
+		if !ValidIdent(name) {
+			t.Fatalf("%s should be valid", name)
+		}
+	}
+	for _, name := range []string{"", "a`b", "id desc", "id;drop", "a.b.c", "name)"} {
+		if ValidIdent(name) {
+			t.Fatalf("%s should be invalid", name)
+		}
+	}
+	db := (&DB{}).SetStrict(true)
+	if err := db.checkWrite("user", H{"name`": 1}); err == nil {
+		t.Fatal("invalid key should be rejected")
+	}
+	if err := db.checkWrite("user u", nil); err == nil {
+		t.Fatal("invalid table should be rejected")
+	}
+	if err := db.checkWrite("@pf_user", H{"name": 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrderSafe(t *testing.T) {
+	slt := NewSelector(nil, "user")
+	slt.OrderSafe("id;drop", "desc").OrderSafe("age", "asc", "id", "name")
+	if slt.orders != nil {
+		t.Fatalf("order = %s", slt.orders.Sql)
+	}
+	slt.OrderSafe("u.id", "DESC", "u.id").OrderSafe("name", "sideways")
+	if slt.orders.Sql != "order by `u`.`id` desc ,`name` asc" {
+		t.Fatalf("order = %s", slt.orders.Sql)
+	}
+}
+
+func TestStrictColumns(t *testing.T) {
+	db := newFakeDB(&fakeServer{})
+	slt := NewSelector(db, "@pf_user")
+	slt.Eq("date(created_at)", "2023-01-01").Gt("u.age", 18)
+	if frame := slt.GetFrame(); frame.Sql != "date(created_at) = ? and `u`.`age` > ?" {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if err := slt.Err(); err != nil {
+		t.Fatal(err)
+	}
+	db.SetStrict(true)
+	if _, err := slt.GetList(); err == nil {
+		t.Fatal("strict mode should reject expression columns")
+	}
+	strict := NewSelector(db, "@pf_user")
+	strict.WhereC(NewCondition().In("id`", []int{1}))
+	if strict.Err() == nil {
+		t.Fatal("nested invalid column should be rejected")
+	}
+	if err := NewSelector(db, "@pf_user").ChunkKey("id desc").Chunk(2, func([]H) error { return nil }); err == nil {
+		t.Fatal("invalid chunk key should be rejected")
+	}
+}
+
+func TestSchemaPrepare(t *testing.T) {
+	db := &DB{prefix: "sd_"}
+	db.schema.tables = map[string]map[string]bool{"sd_user": {"id": true, "name": true}}
+	data := H{"name": "a", "Extra": 1, "u.id": 2}
+	temp, err := db.SetSchemaMode(SchemaDrop).prepareWrite(context.Background(), "@pf_user", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(temp, H{"name": "a", "u.id": 2}) || len(data) != 3 {
+		t.Fatalf("data = %v", temp)
+	}
+	if _, err = db.SetSchemaMode(SchemaReject).prepareWrite(context.Background(), "`@pf_user`", data); err == nil {
+		t.Fatal("unknown key should be rejected")
+	}
+	db.RefreshSchema()
+	if db.schema.tables != nil {
+		t.Fatal("schema should be cleared")
+	}
+}
+
+func TestSchemaTable(t *testing.T) {
+	var queries []string
+	var args [][]any
+	srv := &fakeServer{query: func(query string, a []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		queries = append(queries, query)
+		args = append(args, fakeArgs(a))
+		return []string{"column_name", "column_type", "data_type", "is_nullable", "column_default", "column_key", "extra", "column_comment"},
+			[][]driver.Value{{"id", "int(11)", "int", "NO", nil, "PRI", "", ""}}, nil
+	}}
+	db := newFakeDB(srv)
+	db.prefix = "sd_"
+	if _, err := db.Columns("`shop`.`@pf_user`"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(queries[0], "table_schema=? and table_name=?") || !reflect.DeepEqual(args[0], []any{"shop", "sd_user"}) {
+		t.Fatalf("sql = %s %v", queries[0], args[0])
+	}
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "batch")
+	db.AddHook(&ctxHook{key: ctxKey{}, seen: make(map[string]any)})
+	db.SetSchemaMode(SchemaDrop)
+	if _, err := db.InsertBatchContext(ctx, "@pf_user", []H{{"id": 1, "extra": 2}}, &BatchOptions{MaxPacket: 1024}); err != nil {
+		t.Fatal(err)
+	}
+	hook := db.hooks[0].(*ctxHook)
+	if hook.seen["select column_name"] != "batch" {
+		t.Fatalf("schema lookup should use the caller's context: %v", hook.seen)
+	}
+	if !hasStatement(srv.statements(), "insert into `sd_user` (`id`) values") {
+		t.Fatalf("statements = %q", srv.statements())
+	}
+}
+
+func TestTables(t *testing.T) {
+	srv := &fakeServer{query: func(query string, a []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		return []string{"table_name"}, [][]driver.Value{{"other_log"}, {"sd_role"}, {"sd_user"}}, nil
+	}}
+	db := newFakeDB(srv)
+	tables, err := db.Tables()
+	if err != nil || !reflect.DeepEqual(tables, []string{"other_log", "sd_role", "sd_user"}) {
+		t.Fatalf("tables = %v, err = %v", tables, err)
+	}
+	db.prefix = "sd_"
+	tables, err = db.Tables()
+	if err != nil || !reflect.DeepEqual(tables, []string{"@pf_role", "@pf_user"}) {
+		t.Fatalf("prefixed tables = %v, err = %v", tables, err)
+	}
+}
+
+// ctxHook 按语句开头记录上下文中 key 对应的值
+type ctxHook struct {
+	key  any
+	seen map[string]any
+}
+
+func (h *ctxHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	if len(event.Query) >= 18 {
+		h.seen[event.Query[:18]] = ctx.Value(h.key)
+	}
+	return ctx
+}
+
+func (h *ctxHook) After(ctx context.Context, event *QueryEvent) {}
+
+func TestRegisterReplace(t *testing.T) {
+	first, _ := sql.Open("mysql", "root@tcp(127.0.0.1:1)/test")
+	second, _ := sql.Open("mysql", "root@tcp(127.0.0.1:1)/test")
+	Register("register_test", first, "")
+	db := Register("register_test", second, "sd_")
+	defer Close("register_test")
+	if err := first.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Fatalf("old connection should be closed, err = %v", err)
+	}
+	if got, _ := Use("register_test"); got != db {
+		t.Fatal("registered connection should be returned")
+	}
+}
@@ -0,0 +1,208 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeServer 测试用的数据库，记录执行的语句，查询结果由 query 返回
+type fakeServer struct {
+	mutex sync.Mutex
+	log   []string
+	open  int // 未关闭的结果集数量
+	query func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error)
+	exec  func(query string, args []driver.NamedValue) error
+}
+
+func newFakeDB(srv *fakeServer) *DB {
+	return &DB{DB: sql.OpenDB(&fakeConnector{srv: srv})}
+}
+
+func (srv *fakeServer) record(query string) {
+	srv.mutex.Lock()
+	srv.log = append(srv.log, query)
+	srv.mutex.Unlock()
+}
+
+func (srv *fakeServer) statements() []string {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return append([]string(nil), srv.log...)
+}
+
+func (srv *fakeServer) openRows() int {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return srv.open
+}
+
+type fakeConnector struct {
+	srv *fakeServer
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{srv: c.srv}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeConn struct {
+	srv *fakeServer
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.srv.record("BEGIN")
+	return &fakeTx{srv: c.srv}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.srv.record(query)
+	if c.srv.exec != nil {
+		if err := c.srv.exec(query, args); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.srv.record(query)
+	var columns []string
+	var rows [][]driver.Value
+	if c.srv.query != nil {
+		var err error
+		if columns, rows, err = c.srv.query(query, args); err != nil {
+			return nil, err
+		}
+	}
+	c.srv.mutex.Lock()
+	c.srv.open++
+	c.srv.mutex.Unlock()
+	return &fakeRows{srv: c.srv, columns: columns, rows: rows}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	list := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		list[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return list
+}
+
+type fakeTx struct {
+	srv *fakeServer
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.srv.record("COMMIT")
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.srv.record("ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	srv     *fakeServer
+	columns []string
+	rows    [][]driver.Value
+	closed  bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+// ColumnTypeDatabaseTypeName 按第一行的值推断字段类型
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string {
+	if len(r.rows) == 0 {
+		return "VARCHAR"
+	}
+	switch r.rows[0][index].(type) {
+	case int64:
+		return "BIGINT"
+	case float64:
+		return "DOUBLE"
+	}
+	return "VARCHAR"
+}
+
+func (r *fakeRows) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.srv.mutex.Lock()
+		r.srv.open--
+		r.srv.mutex.Unlock()
+	}
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// fakeArgs 将参数转为普通切片，便于比较
+func fakeArgs(args []driver.NamedValue) []any {
+	list := make([]any, len(args))
+	for i, arg := range args {
+		list[i] = arg.Value
+	}
+	return list
+}
+
+// hasStatement 判断是否执行过以 prefix 开头的语句
+func hasStatement(list []string, prefix string) bool {
+	for _, query := range list {
+		if strings.HasPrefix(query, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+type FetchMode int
+
+const (
+	FetchDefault FetchMode = 0 // NULL 转为零值，兼容原有行为
+	FetchTyped   FetchMode = 1 // 保留 NULL 为 nil，并按字段类型精确转换
+)
+
+type fetchModeKey struct{}
+
+// WithFetchMode 返回指定读取模式的上下文，用于单次查询
+func WithFetchMode(ctx context.Context, mode FetchMode) context.Context {
+	return context.WithValue(ctx, fetchModeKey{}, mode)
+}
+
+// SetFetchMode 设置连接默认的读取模式
+func (db *DB) SetFetchMode(mode FetchMode) *DB {
+	db.mode = mode
+	return db
+}
+
+func (db *DB) fetchModeOf(ctx context.Context) FetchMode {
+	if mode, ok := ctx.Value(fetchModeKey{}).(FetchMode); ok {
+		return mode
+	}
+	return db.mode
+}
+
+// 精确模式下的字段类型
+const (
+	kindString = iota
+	kindInt
+	kindBigInt
+	kindFloat
+	kindDecimal
+	kindTime
+	kindBytes
+	kindJson
+	kindBit
+)
+
+// rowReader 按字段类型读取结果集的每一行
+type rowReader struct {
+	rows    *sql.Rows
+	columns []*sql.ColumnType
+	kinds   []int
+	cache   []any
+	mode    FetchMode
+}
+
+func newRowReader(rows *sql.Rows, mode FetchMode) (*rowReader, error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	r := &rowReader{
+		rows:    rows,
+		columns: columns,
+		kinds:   make([]int, len(columns)),
+		cache:   make([]any, len(columns)),
+		mode:    mode,
+	}
+	for index, column := range columns {
+		typeName := column.DatabaseTypeName()
+		if mode == FetchTyped {
+			r.kinds[index], r.cache[index] = typedTarget(typeName)
+			continue
+		}
+		switch typeName {
+		case "INT", "TINYINT", "INTEGER", "BIGINT", "SMALLINT", "MEDIUMINT":
+			var a sql.NullInt64
+			r.cache[index] = &a
+			break
+		case "FLOAT", "DOUBLE", "DECIMAL":
+			var a sql.NullFloat64
+			r.cache[index] = &a
+			break
+		case "SMALLDATETIME", "DATETIME", "DATE":
+			var a sql.NullTime
+			r.cache[index] = &a
+			break
+		default:
+			var a sql.NullString
+			r.cache[index] = &a
+			break
+		}
+	}
+	return r, nil
+}
+
+// typedTarget 精确模式下字段的类型和扫描对象
+func typedTarget(typeName string) (int, any) {
+	switch typeName {
+	case "INT", "TINYINT", "INTEGER", "SMALLINT", "MEDIUMINT", "YEAR":
+		return kindInt, new(sql.NullInt64)
+	case "BIGINT":
+		return kindBigInt, new(sql.NullString)
+	case "FLOAT", "DOUBLE":
+		return kindFloat, new(sql.NullFloat64)
+	case "DECIMAL":
+		return kindDecimal, new(sql.NullString)
+	case "SMALLDATETIME", "DATETIME", "DATE", "TIMESTAMP":
+		return kindTime, new(sql.NullTime)
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "GEOMETRY":
+		return kindBytes, new([]byte)
+	case "JSON":
+		return kindJson, new([]byte)
+	case "BIT":
+		return kindBit, new([]byte)
+	default:
+		return kindString, new(sql.NullString)
+	}
+}
+
+// read 扫描当前行并转换为 H
+func (r *rowReader) read() (H, error) {
+	if err := r.rows.Scan(r.cache...); err != nil {
+		return nil, err
+	}
+	item := make(H, len(r.cache))
+	for i, data := range r.cache {
+		key := r.columns[i].Name()
+		if r.mode == FetchTyped {
+			item[key] = typedValue(r.kinds[i], data)
+			continue
+		}
+		switch data.(type) {
+		case *sql.NullString:
+			a := *data.(*sql.NullString)
+			if a.Valid {
+				item[key] = a.String
+			} else {
+				item[key] = ""
+			}
+			break
+		case *sql.NullInt64:
+			a := *data.(*sql.NullInt64)
+			if a.Valid {
+				item[key] = int(a.Int64)
+			} else {
+				item[key] = 0
+			}
+			break
+		case *sql.NullTime:
+			a := *data.(*sql.NullTime)
+			if a.Valid {
+				item[key] = a.Time
+			} else {
+				item[key] = time.Time{}
+			}
+			break
+		case *sql.NullFloat64:
+			a := *data.(*sql.NullFloat64)
+			if a.Valid {
+				item[key] = a.Float64
+			} else {
+				item[key] = float64(0)
+			}
+			break
+		default:
+			item[key] = *data.(*any)
+			break
+		}
+	}
+	return item, nil
+}
+
+// typedValue 精确模式下转换字段值，NULL 返回 nil
+func typedValue(kind int, data any) any {
+	switch v := data.(type) {
+	case *sql.NullInt64:
+		if !v.Valid {
+			return nil
+		}
+		return int(v.Int64)
+	case *sql.NullFloat64:
+		if !v.Valid {
+			return nil
+		}
+		return v.Float64
+	case *sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time
+	case *sql.NullString:
+		if !v.Valid {
+			return nil
+		}
+		if kind == kindBigInt {
+			if n, err := strconv.ParseInt(v.String, 10, 64); err == nil {
+				return int(n)
+			}
+			if n, err := strconv.ParseUint(v.String, 10, 64); err == nil {
+				return n
+			}
+		}
+		return v.String
+	case *[]byte:
+		if *v == nil {
+			return nil
+		}
+		switch kind {
+		case kindJson:
+			return json.RawMessage(*v)
+		case kindBit:
+			var n uint64
+			for _, b := range *v {
+				n = n<<8 | uint64(b)
+			}
+			return n
+		}
+		return *v
+	}
+	return nil
+}
+
+// fetch 遍历数据
+func fetch(rows *sql.Rows, mode FetchMode) ([]H, error) {
+	defer rows.Close()
+	reader, err := newRowReader(rows, mode)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]H, 0)
+	for rows.Next() {
+		item, err := reader.read()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,217 @@
+package dbs
+
+import (
+	"fmt"
+	"github.com/wj008/goyee/config"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter 单个参数的过滤规则
+type Filter struct {
+	Column string     // 数据库字段，默认与参数名相同
+	Op     string     // eq ne gt gte lt lte in notin like prefix suffix between，默认 eq
+	Type   string     // string int float bool time，默认 string
+	Search SearchType // 空值处理方式，默认 WithoutEmpty
+}
+
+// FilterSpec 参数名到过滤规则的映射，未列出的参数会被忽略
+type FilterSpec map[string]Filter
+
+var filterOps = map[string]bool{"": true, "eq": true, "ne": true, "gt": true, "gte": true, "lt": true, "lte": true, "in": true, "notin": true, "like": true, "prefix": true, "suffix": true, "between": true}
+
+var filterTypes = map[string]bool{"": true, "string": true, "int": true, "float": true, "bool": true, "time": true}
+
+// Validate 检查规则中的操作符和类型是否支持
+func (spec FilterSpec) Validate() error {
+	names := make([]string, 0, len(spec))
+	for name := range spec {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filter := spec[name]
+		if !filterOps[strings.ToLower(filter.Op)] {
+			return fmt.Errorf("参数 %s 的操作符 %s 不支持", name, filter.Op)
+		}
+		if !filterTypes[strings.ToLower(filter.Type)] {
+			return fmt.Errorf("参数 %s 的类型 %s 不支持", name, filter.Type)
+		}
+	}
+	return nil
+}
+
+// coerceFilter 按类型转换参数值，转换失败返回 nil
+func coerceFilter(typ string, value any) any {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return ""
+	}
+	switch strings.ToLower(typ) {
+	case "int":
+		if v, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return int(v)
+		}
+		return nil
+	case "float":
+		if v, err := strconv.ParseFloat(str, 64); err == nil {
+			return v
+		}
+		return nil
+	case "bool":
+		switch strings.ToLower(str) {
+		case "1", "true", "on", "yes":
+			return true
+		case "0", "false", "off", "no":
+			return false
+		}
+		return nil
+	case "time":
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+			if v, err := time.ParseInLocation(layout, str, config.CstZone()); err == nil {
+				return v.Format("2006-01-02 15:04:05")
+			}
+		}
+		return nil
+	}
+	return str
+}
+
+// filterList 将参数转为列表，字符串按逗号分隔
+func filterList(typ string, value any) []any {
+	var items []any
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return []any{}
+		}
+		for _, item := range strings.Split(v, ",") {
+			items = append(items, item)
+		}
+	case []string:
+		for _, item := range v {
+			items = append(items, item)
+		}
+	default:
+		items = toSlice(value)
+	}
+	list := make([]any, 0, len(items))
+	for _, item := range items {
+		item = coerceFilter(typ, item)
+		if item != nil && item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// applyFilter 按规则添加一个查询条件，between 的参数必须正好是 2 个值
+func (cond *Condition) applyFilter(name string, filter Filter, value any) error {
+	column := filter.Column
+	if column == "" {
+		column = name
+	}
+	op := strings.ToLower(filter.Op)
+	switch op {
+	case "in", "notin", "between":
+		list := filterList(filter.Type, value)
+		if skip, _ := skipSearch(list, filter.Search); skip {
+			return nil
+		}
+		if op == "in" {
+			cond.In(column, list)
+		} else if op == "notin" {
+			cond.NotIn(column, list)
+		} else if len(list) == 2 {
+			cond.Between(column, list[0], list[1])
+		} else {
+			return fmt.Errorf("参数 %s 需要 2 个值，实际为 %d 个", name, len(list))
+		}
+		return nil
+	}
+	if list, ok := value.([]string); ok {
+		if len(list) == 0 {
+			return nil
+		}
+		value = list[0]
+	}
+	value = coerceFilter(filter.Type, value)
+	if skip, _ := skipSearch(value, filter.Search); skip {
+		return nil
+	}
+	switch op {
+	case "", "eq":
+		cond.Eq(column, value)
+	case "ne":
+		cond.Ne(column, value)
+	case "gt":
+		cond.Gt(column, value)
+	case "gte":
+		cond.Gte(column, value)
+	case "lt":
+		cond.Lt(column, value)
+	case "lte":
+		cond.Lte(column, value)
+	case "like", "prefix", "suffix":
+		str, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		if op == "like" {
+			cond.Like(column, str)
+		} else if op == "prefix" {
+			cond.StartsWith(column, str)
+		} else {
+			cond.EndsWith(column, str)
+		}
+	default:
+		return fmt.Errorf("参数 %s 的操作符 %s 不支持", name, filter.Op)
+	}
+	return nil
+}
+
+// FromMap 按规则将参数转为查询条件，只处理 spec 中列出的参数，类型转换失败的参数会被忽略
+// 规则不合法或参数个数不符时返回错误，此时不会添加任何条件
+func (cond *Condition) FromMap(data H, spec FilterSpec) (*Condition, error) {
+	if err := spec.Validate(); err != nil {
+		return cond, err
+	}
+	names := make([]string, 0, len(spec))
+	for name := range spec {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	temp := NewCondition()
+	for _, name := range names {
+		value, ok := data[name]
+		if !ok {
+			continue
+		}
+		if err := temp.applyFilter(name, spec[name], value); err != nil {
+			return cond, err
+		}
+	}
+	cond.items = append(cond.items, temp.items...)
+	cond.setExpr(temp.expr)
+	return cond, nil
+}
+
+// FromValues 按规则将 url.Values 转为查询条件，多值参数用于 in、notin、between
+func (cond *Condition) FromValues(values url.Values, spec FilterSpec) (*Condition, error) {
+	data := make(H, len(values))
+	for name, items := range values {
+		if len(items) == 1 {
+			data[name] = items[0]
+		} else {
+			data[name] = items
+		}
+	}
+	return cond.FromMap(data, spec)
+}
@@ -42,6 +42,58 @@ func (frame *Frame) Format() string {
 	return str
 }
 
+// selectorErr 返回参数中 *Selector 子查询构建时的错误
+func selectorErr(args ...any) error {
+	for _, arg := range args {
+		if v, ok := arg.(*Selector); ok {
+			if err := v.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandArgs 将参数中的 *Selector 和 *Frame 展开为子查询，并按位置合并参数
+func expandArgs(sql string, args []any) (string, []any) {
+	found := false
+	for _, arg := range args {
+		switch arg.(type) {
+		case *Selector, *Frame:
+			found = true
+		}
+	}
+	if !found || strings.Count(sql, "?") != len(args) {
+		return sql, args
+	}
+	buf := strings.Builder{}
+	temps := make([]any, 0, len(args))
+	argPos := 0
+	for i := 0; i < len(sql); i++ {
+		if sql[i] != '?' {
+			buf.WriteByte(sql[i])
+			continue
+		}
+		arg := args[argPos]
+		argPos++
+		var frame *Frame
+		switch v := arg.(type) {
+		case *Selector:
+			frame = v.BuildSql(false)
+		case *Frame:
+			frame = v
+		default:
+			buf.WriteByte('?')
+			temps = append(temps, arg)
+			continue
+		}
+		subSql, subArgs := expandArgs(frame.Sql, frame.Args)
+		buf.WriteString("(" + subSql + ")")
+		temps = append(temps, subArgs...)
+	}
+	return buf.String(), temps
+}
+
 func reserveBuffer(buf []byte, appendSize int) []byte {
 	newSize := len(buf) + appendSize
 	if cap(buf) < newSize {
@@ -0,0 +1,118 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"github.com/wj008/goyee/logger"
+	"time"
+)
+
+// QueryEvent SQL 执行事件
+type QueryEvent struct {
+	Op           string        // exec 或 query
+	Query        string        // 替换表前缀后的语句
+	Args         []any         // 语句参数
+	Start        time.Time     // 开始时间
+	Duration     time.Duration // 执行耗时，Before 中为 0
+	RowsAffected int64         // 影响行数，仅 exec 有效
+	Err          error         // 执行错误
+}
+
+// Hook SQL 执行钩子，Before 返回的上下文会传给本次执行和 After
+type Hook interface {
+	Before(ctx context.Context, event *QueryEvent) context.Context
+	After(ctx context.Context, event *QueryEvent)
+}
+
+// AddHook 注册 SQL 执行钩子，应在使用连接前注册，事务同样会执行这些钩子
+func (db *DB) AddHook(hook Hook) *DB {
+	db.hooks = append(db.hooks, hook)
+	return db
+}
+
+// hookCall 一次执行的钩子调用，结束时调用 finish
+type hookCall struct {
+	ctx   context.Context
+	hooks []Hook
+	event *QueryEvent
+}
+
+// startHooks 调用 Before 钩子，没有钩子时返回 nil
+func startHooks(ctx context.Context, hooks []Hook, op string, query string, args []any) *hookCall {
+	if len(hooks) == 0 {
+		return nil
+	}
+	event := &QueryEvent{
+		Op:    op,
+		Query: query,
+		Args:  args,
+		Start: time.Now(),
+	}
+	for _, hook := range hooks {
+		ctx = hook.Before(ctx, event)
+	}
+	return &hookCall{ctx: ctx, hooks: hooks, event: event}
+}
+
+// finish 记录耗时和结果并调用 After 钩子
+func (c *hookCall) finish(result any, err error) {
+	if c == nil {
+		return
+	}
+	event := c.event
+	event.Duration = time.Since(event.Start)
+	event.Err = err
+	if res, ok := result.(sql.Result); ok && err == nil && res != nil {
+		if n, e := res.RowsAffected(); e == nil {
+			event.RowsAffected = n
+		}
+	}
+	for _, hook := range c.hooks {
+		hook.After(c.ctx, event)
+	}
+}
+
+// withHooks 在执行前后调用钩子，耗时包含 run 的全部执行时间
+func withHooks[T any](ctx context.Context, hooks []Hook, op string, query string, args []any, run func(ctx context.Context) (T, error)) (T, error) {
+	call := startHooks(ctx, hooks, op, query, args)
+	if call == nil {
+		return run(ctx)
+	}
+	result, err := run(call.ctx)
+	call.finish(result, err)
+	return result, err
+}
+
+// LogHook 通过 logger 输出 SQL 日志，并记录慢查询
+type LogHook struct {
+	Level int           // logger.LPrint 的输出等级
+	Slow  time.Duration // 慢查询阈值，超过时无论等级都会输出，0 表示不检测
+}
+
+// NewLogHook 按配置创建日志钩子，配置项为 db_log_level 和 db_slow_ms，命名连接使用 db_{name}_log_level
+func NewLogHook(name string) *LogHook {
+	return &LogHook{
+		Level: cfgInt(name, "log_level", 1),
+		Slow:  time.Duration(cfgInt(name, "slow_ms", 0)) * time.Millisecond,
+	}
+}
+
+func (h *LogHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *LogHook) After(ctx context.Context, event *QueryEvent) {
+	if h.Slow > 0 && event.Duration >= h.Slow {
+		if event.Err != nil {
+			logger.Printf("[SLOW SQL] %s %v %s error: %s\n", event.Query, event.Args, event.Duration, event.Err.Error())
+			return
+		}
+		logger.Printf("[SLOW SQL] %s %v %s\n", event.Query, event.Args, event.Duration)
+		return
+	}
+	if event.Err != nil {
+		logger.LPrintf(h.Level, "[SQL] %s %v %s error: %s\n", event.Query, event.Args, event.Duration, event.Err.Error())
+		return
+	}
+	logger.LPrintf(h.Level, "[SQL] %s %v %s rows: %d\n", event.Query, event.Args, event.Duration, event.RowsAffected)
+}
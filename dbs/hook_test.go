@@ -0,0 +1,111 @@
+package dbs
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordHook struct {
+	before int
+	events []QueryEvent
+}
+
+type hookKey struct{}
+
+func (h *recordHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	h.before++
+	return context.WithValue(ctx, hookKey{}, h.before)
+}
+
+func (h *recordHook) After(ctx context.Context, event *QueryEvent) {
+	if ctx.Value(hookKey{}) != h.before {
+		panic("After should receive the context returned by Before")
+	}
+	h.events = append(h.events, *event)
+}
+
+func TestWithHooks(t *testing.T) {
+	hook := &recordHook{}
+	fail := errors.New("fail")
+	_, err := withHooks(context.Background(), []Hook{hook}, "exec", "update t", []any{1}, func(ctx context.Context) (driver.Result, error) {
+		if ctx.Value(hookKey{}) != 1 {
+			t.Fatal("run should receive the context returned by Before")
+		}
+		return nil, fail
+	})
+	if err != fail {
+		t.Fatalf("err = %v", err)
+	}
+	res, err := withHooks(context.Background(), []Hook{hook}, "exec", "update t", nil, func(ctx context.Context) (driver.Result, error) {
+		time.Sleep(time.Millisecond)
+		return driver.RowsAffected(3), nil
+	})
+	if err != nil || res == nil {
+		t.Fatal(err)
+	}
+	if len(hook.events) != 2 {
+		t.Fatalf("events = %d", len(hook.events))
+	}
+	if hook.events[0].Err != fail || hook.events[0].Op != "exec" || hook.events[0].Args[0] != 1 {
+		t.Fatalf("first event = %+v", hook.events[0])
+	}
+	if hook.events[1].RowsAffected != 3 || hook.events[1].Duration < time.Millisecond {
+		t.Fatalf("second event = %+v", hook.events[1])
+	}
+}
+
+func TestHookFetchDuration(t *testing.T) {
+	hook := &recordHook{}
+	srv := &fakeServer{query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		return []string{"id"}, [][]driver.Value{{int64(1)}, {int64(2)}}, nil
+	}}
+	db := newFakeDB(srv)
+	db.AddHook(hook)
+	iter, err := db.QueryIter("select id from @pf_user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.events) != 0 {
+		t.Fatal("iterator should report after it is closed")
+	}
+	time.Sleep(time.Millisecond)
+	if err = iter.Each(func(H) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.events) != 1 || hook.events[0].Duration < time.Millisecond {
+		t.Fatalf("events = %+v", hook.events)
+	}
+	iter.Close()
+	if len(hook.events) != 1 {
+		t.Fatal("closing twice should not report again")
+	}
+	if _, err = db.Query("select id from @pf_user"); err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.events) != 2 || hook.events[1].Query != "select id from user" {
+		t.Fatalf("events = %+v", hook.events)
+	}
+}
+
+func TestLogHook(t *testing.T) {
+	var buf bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(output)
+	hook := &LogHook{Level: 99, Slow: time.Millisecond}
+	hook.After(context.Background(), &QueryEvent{Query: "select 1", Duration: time.Microsecond})
+	if buf.Len() != 0 {
+		t.Fatalf("fast query should follow the log level: %q", buf.String())
+	}
+	hook.After(context.Background(), &QueryEvent{Query: "select 2", Duration: time.Second, Err: errors.New("timeout")})
+	line := buf.String()
+	if !strings.Contains(line, "[SLOW SQL] select 2") || !strings.Contains(line, "error: timeout") {
+		t.Fatalf("slow log = %q", line)
+	}
+}
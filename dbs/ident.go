@@ -0,0 +1,75 @@
+package dbs
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// identReg 合法的标识符，允许表前缀占位符和 库.表 形式
+var identReg = regexp.MustCompile(`^(@pf_)?[\w$]+(\.(@pf_)?[\w$]+)?$`)
+
+// QuoteIdent 用反引号包裹标识符，内部的反引号会被转义，a.b 会被拆分为 `a`.`b`
+func QuoteIdent(name string) string {
+	parts := strings.Split(strings.TrimSpace(name), ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// ValidIdent 判断是否只包含标识符字符，用于校验来自外部的表名和字段名
+func ValidIdent(name string) bool {
+	return identReg.MatchString(name)
+}
+
+// SetStrict 设置严格模式，开启后写入方法会拒绝非法的表名和字段名，查询条件中不是合法标识符的字段也会被拒绝
+func (db *DB) SetStrict(strict bool) *DB {
+	db.strict = strict
+	return db
+}
+
+// checkWrite 严格模式下校验写入的表名和字段名
+func (db *DB) checkWrite(table string, data H) error {
+	if !db.strict {
+		return nil
+	}
+	if !ValidIdent(table) {
+		return errors.New("非法的表名: " + table)
+	}
+	for key := range data {
+		if !ValidIdent(key) {
+			return errors.New("非法的字段名: " + key)
+		}
+	}
+	return nil
+}
+
+/*
+*
+安全排序，字段必须是合法的标识符且在允许列表中(列表为空时不限制)，否则忽略
+dir 只接受 asc 或 desc，其他值按 asc 处理
+*/
+func (slt *Selector) OrderSafe(column string, dir string, allowlist ...string) *Selector {
+	column = strings.TrimSpace(column)
+	if !ValidIdent(column) {
+		return slt
+	}
+	if len(allowlist) > 0 {
+		allowed := false
+		for _, item := range allowlist {
+			if item == column {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return slt
+		}
+	}
+	dir = strings.ToLower(strings.TrimSpace(dir))
+	if dir != "desc" {
+		dir = "asc"
+	}
+	return slt.Order(QuoteIdent(column) + " " + dir)
+}
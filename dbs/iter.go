@@ -0,0 +1,129 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrStop 遍历回调返回该错误时提前结束，遍历本身不返回错误
+var ErrStop = errors.New("stop iteration")
+
+// Iter 逐行读取结果集，使用完毕必须调用 Close 或遍历到结束
+type Iter struct {
+	rows   *sql.Rows
+	reader *rowReader
+	item   H
+	err    error
+	hook   *hookCall // 关闭时调用 After 钩子，耗时包含遍历的时间
+}
+
+func newIter(rows *sql.Rows, mode FetchMode, hook *hookCall) (*Iter, error) {
+	reader, err := newRowReader(rows, mode)
+	if err != nil {
+		rows.Close()
+		hook.finish(nil, err)
+		return nil, err
+	}
+	return &Iter{rows: rows, reader: reader, hook: hook}, nil
+}
+
+// Next 读取下一行，没有更多数据或出错时返回 false 并关闭结果集
+func (it *Iter) Next() bool {
+	if it.err != nil || it.rows == nil {
+		return false
+	}
+	if !it.rows.Next() {
+		it.err = it.rows.Err()
+		it.Close()
+		return false
+	}
+	it.item, it.err = it.reader.read()
+	if it.err != nil {
+		it.Close()
+		return false
+	}
+	return true
+}
+
+// Row 当前行数据
+func (it *Iter) Row() H {
+	return it.item
+}
+
+// Err 遍历过程中的错误
+func (it *Iter) Err() error {
+	return it.err
+}
+
+// Close 关闭结果集，可重复调用
+func (it *Iter) Close() error {
+	if it.rows == nil {
+		return nil
+	}
+	rows := it.rows
+	it.rows = nil
+	err := rows.Close()
+	if it.err != nil {
+		it.hook.finish(nil, it.err)
+	} else {
+		it.hook.finish(nil, err)
+	}
+	it.hook = nil
+	return err
+}
+
+// Each 逐行执行 fn，fn 返回 ErrStop 时提前结束，结束后总会关闭结果集
+func (it *Iter) Each(fn func(H) error) error {
+	defer it.Close()
+	for it.Next() {
+		if err := fn(it.item); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return it.err
+}
+
+// QueryIter 查询并返回逐行读取的迭代器
+func (db *DB) QueryIter(query string, args ...any) (*Iter, error) {
+	return db.QueryIterContext(context.Background(), query, args...)
+}
+
+// QueryIterContext 带有上下文查询并返回逐行读取的迭代器
+func (db *DB) QueryIterContext(ctx context.Context, query string, args ...any) (*Iter, error) {
+	query = strings.Replace(query, "@pf_", db.prefix, -1)
+	hook := startHooks(ctx, db.hooks, "query", query, args)
+	if hook != nil {
+		ctx = hook.ctx
+	}
+	rows, err := db.queryRows(ctx, query, args)
+	if err != nil {
+		hook.finish(nil, err)
+		return nil, err
+	}
+	return newIter(rows, db.fetchModeOf(ctx), hook)
+}
+
+// QueryIter 查询并返回逐行读取的迭代器
+func (tx *Tx) QueryIter(query string, args ...any) (*Iter, error) {
+	return tx.QueryIterContext(context.Background(), query, args...)
+}
+
+// QueryIterContext 带有上下文查询并返回逐行读取的迭代器
+func (tx *Tx) QueryIterContext(ctx context.Context, query string, args ...any) (*Iter, error) {
+	query = strings.Replace(query, "@pf_", tx.prefix, -1)
+	hook := startHooks(ctx, tx.db.hooks, "query", query, args)
+	if hook != nil {
+		ctx = hook.ctx
+	}
+	rows, err := tx.queryRows(ctx, query, args)
+	if err != nil {
+		hook.finish(nil, err)
+		return nil, err
+	}
+	return newIter(rows, tx.db.fetchModeOf(ctx), hook)
+}
@@ -0,0 +1,115 @@
+package dbs
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func iterServer() *fakeServer {
+	return &fakeServer{query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		return []string{"id"}, [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}}, nil
+	}}
+}
+
+func TestIterEach(t *testing.T) {
+	srv := iterServer()
+	db := newFakeDB(srv)
+	ids := make([]any, 0)
+	iter, err := db.QueryIter("select id from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = iter.Each(func(row H) error {
+		ids = append(ids, row["id"])
+		if len(ids) == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ErrStop should not be returned: %v", err)
+	}
+	if len(ids) != 2 || srv.openRows() != 0 {
+		t.Fatalf("ids = %v, open rows = %d", ids, srv.openRows())
+	}
+	fail := errors.New("fail")
+	iter, err = db.QueryIter("select id from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = iter.Each(func(H) error { return fail }); err != fail {
+		t.Fatalf("err = %v", err)
+	}
+	if srv.openRows() != 0 {
+		t.Fatal("rows should be closed after a callback error")
+	}
+}
+
+func TestIterNext(t *testing.T) {
+	srv := iterServer()
+	db := newFakeDB(srv)
+	iter, err := db.QueryIter("select id from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for iter.Next() {
+		count++
+	}
+	if count != 3 || iter.Err() != nil || srv.openRows() != 0 {
+		t.Fatalf("count = %d, err = %v, open rows = %d", count, iter.Err(), srv.openRows())
+	}
+	iter, err = db.QueryIter("select id from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter.Next()
+	if err = iter.Close(); err != nil || srv.openRows() != 0 {
+		t.Fatalf("close = %v, open rows = %d", err, srv.openRows())
+	}
+	if iter.Next() {
+		t.Fatal("closed iterator should not return rows")
+	}
+}
+
+func TestIterTx(t *testing.T) {
+	srv := iterServer()
+	db := newFakeDB(srv)
+	err := db.Transaction(func(tx *Tx) error {
+		iter, err := tx.QueryIter("select id from user")
+		if err != nil {
+			return err
+		}
+		return iter.Each(func(H) error { return ErrStop })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.openRows() != 0 {
+		t.Fatal("rows should be closed")
+	}
+}
+
+func TestSelectorEach(t *testing.T) {
+	srv := iterServer()
+	db := newFakeDB(srv)
+	fail := errors.New("fail")
+	count := 0
+	err := NewSelector(db, "@pf_user").Each(func(H) error {
+		count++
+		return fail
+	})
+	if err != fail || count != 1 {
+		t.Fatalf("err = %v, count = %d", err, count)
+	}
+	srv.query = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		return nil, nil, fail
+	}
+	if err = NewSelector(db, "@pf_user").Each(func(H) error { return nil }); err != fail {
+		t.Fatalf("query error = %v", err)
+	}
+	if srv.openRows() != 0 {
+		t.Fatal("rows should be closed")
+	}
+}
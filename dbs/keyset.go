@@ -0,0 +1,212 @@
+package dbs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeysetPage 游标分页结果
+type KeysetPage struct {
+	List []H    `json:"list"`
+	Next string `json:"next"` // 下一页游标，没有更多数据时为空
+	Prev string `json:"prev"` // 上一页游标，已经是第一页时为空
+}
+
+type keysetColumn struct {
+	column string
+	key    string
+	desc   bool
+}
+
+type keysetCursor struct {
+	Prev   bool  `json:"p,omitempty"`
+	Values []any `json:"v"`
+}
+
+// parseKeysetOrders 解析排序字段，如 "created_at desc"、"a.id asc"
+func parseKeysetOrders(orders []string) ([]keysetColumn, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("游标分页至少需要一个排序字段")
+	}
+	columns := make([]keysetColumn, 0, len(orders))
+	for _, order := range orders {
+		parts := strings.Fields(order)
+		if len(parts) == 0 || len(parts) > 2 || !ValidIdent(parts[0]) {
+			return nil, errors.New("游标分页排序字段错误: " + order)
+		}
+		col := keysetColumn{column: QuoteIdent(parts[0])}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				col.desc = true
+			default:
+				return nil, errors.New("游标分页排序方向错误: " + order)
+			}
+		}
+		col.key = parts[0]
+		if i := strings.LastIndex(col.key, "."); i >= 0 {
+			col.key = col.key[i+1:]
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// encodeCursor 将行中的排序字段编码为游标，查询结果中必须包含所有排序字段
+func encodeCursor(columns []keysetColumn, row H, prev bool) (string, error) {
+	cursor := keysetCursor{Prev: prev, Values: make([]any, 0, len(columns))}
+	for _, col := range columns {
+		value, ok := row[col.key]
+		if !ok {
+			return "", errors.New("游标分页的查询结果缺少排序字段: " + col.key)
+		}
+		if t, ok := value.(time.Time); ok {
+			value = t.Format("2006-01-02 15:04:05.999999")
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解析游标
+func decodeCursor(columns []keysetColumn, cursor string) (*keysetCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("游标格式错误")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	item := &keysetCursor{}
+	if err = decoder.Decode(item); err != nil || len(item.Values) != len(columns) {
+		return nil, errors.New("游标格式错误")
+	}
+	for i, value := range item.Values {
+		if n, ok := value.(json.Number); ok {
+			if v, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+				item.Values[i] = v
+			} else if v, err := strconv.ParseFloat(string(n), 64); err == nil {
+				item.Values[i] = v
+			}
+		}
+	}
+	return item, nil
+}
+
+// keysetWhere 生成游标位置之后的查询条件，支持多个字段和混合排序方向
+// 例如 a asc, b desc 生成 (a > ? or (a = ? and b < ?))
+func keysetWhere(columns []keysetColumn, values []any, prev bool) *Frame {
+	items := make([]string, 0, len(columns))
+	args := make([]any, 0)
+	for i, col := range columns {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[j].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if col.desc != prev {
+			op = "<"
+		}
+		parts = append(parts, col.column+" "+op+" ?")
+		args = append(args, values[i])
+		if len(parts) == 1 {
+			items = append(items, parts[0])
+		} else {
+			items = append(items, "("+strings.Join(parts, " and ")+")")
+		}
+	}
+	return NewFrame("("+strings.Join(items, " or ")+")", "where", args...)
+}
+
+/*
+*
+游标分页，orders 为排序字段，最后一个字段必须唯一，cursor 为空时获取第一页
+*/
+func (slt *Selector) KeysetList(cursor string, size int, orders ...string) (*KeysetPage, error) {
+	return slt.KeysetListContext(slt.context(), cursor, size, orders...)
+}
+
+/*
+*
+带有上下文的游标分页
+*/
+func (slt *Selector) KeysetListContext(ctx context.Context, cursor string, size int, orders ...string) (*KeysetPage, error) {
+	if err := slt.Err(); err != nil {
+		return nil, err
+	}
+	columns, err := parseKeysetOrders(orders)
+	if err != nil {
+		return nil, err
+	}
+	if size < 1 {
+		size = 20
+	}
+	prev := false
+	items := slt.Condition.items
+	oldOrders := slt.orders
+	oldLimit := slt.limit
+	defer func() {
+		slt.Condition.items = items
+		slt.orders = oldOrders
+		slt.limit = oldLimit
+	}()
+	if cursor != "" {
+		item, err := decodeCursor(columns, cursor)
+		if err != nil {
+			return nil, err
+		}
+		prev = item.Prev
+		slt.Condition.items = appendGroup(items, keysetWhere(columns, item.Values, prev))
+	}
+	sorts := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col.desc != prev {
+			sorts = append(sorts, col.column+" desc")
+		} else {
+			sorts = append(sorts, col.column+" asc")
+		}
+	}
+	slt.orders = nil
+	slt.Order(strings.Join(sorts, ","))
+	slt.Limit(0, size+1)
+	frame := slt.BuildSql(false)
+	list, err := slt.db.QueryContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	more := len(list) > size
+	if more {
+		list = list[:size]
+	}
+	page := &KeysetPage{List: list}
+	if len(list) == 0 {
+		return page, nil
+	}
+	if prev {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	if more || prev {
+		if page.Next, err = encodeCursor(columns, list[len(list)-1], false); err != nil {
+			return nil, err
+		}
+	}
+	if (prev && more) || (!prev && cursor != "") {
+		if page.Prev, err = encodeCursor(columns, list[0], true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
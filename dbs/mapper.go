@@ -0,0 +1,229 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Querier 可以返回原始结果集的查询对象，DB 和 Tx 均实现该接口
+type Querier interface {
+	QueryRowsContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type fieldInfo struct {
+	name      string
+	index     []int
+	pk        bool
+	auto      bool
+	omitempty bool
+	readonly  bool
+}
+
+type structInfo struct {
+	fields []*fieldInfo
+	names  map[string]*fieldInfo
+}
+
+var structCache sync.Map
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// getStructInfo 解析结构体的 db 标签，结果会被缓存
+//
+// 标签格式为 `db:"name,opt..."`，可用选项：pk 主键，auto 自增（插入时跳过零值并回写ID），
+// omitempty 零值时不写入，readonly 只读不写入。只有一个整数类型的 pk 主键时默认视为自增。
+func getStructInfo(typ reflect.Type) *structInfo {
+	if info, ok := structCache.Load(typ); ok {
+		return info.(*structInfo)
+	}
+	info := &structInfo{
+		fields: make([]*fieldInfo, 0),
+		names:  make(map[string]*fieldInfo),
+	}
+	walkStruct(typ, nil, info)
+	autoPk(typ, info)
+	structCache.Store(typ, info)
+	return info
+}
+
+func walkStruct(typ reflect.Type, parent []int, info *structInfo) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		index := make([]int, len(parent)+1)
+		copy(index, parent)
+		index[len(parent)] = i
+		name, opts, _ := strings.Cut(tag, ",")
+		name = strings.TrimSpace(name)
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !reflect.PointerTo(ft).Implements(scannerType) {
+				walkStruct(ft, index, info)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := strings.ToLower(name)
+		if _, ok := info.names[key]; ok {
+			//外层字段优先于嵌入字段
+			continue
+		}
+		item := &fieldInfo{name: name, index: index}
+		for _, opt := range strings.Split(opts, ",") {
+			switch strings.TrimSpace(opt) {
+			case "pk":
+				item.pk = true
+			case "auto":
+				item.auto = true
+			case "omitempty":
+				item.omitempty = true
+			case "readonly":
+				item.readonly = true
+			}
+		}
+		info.fields = append(info.fields, item)
+		info.names[key] = item
+	}
+}
+
+// autoPk 唯一的整数主键视为自增
+func autoPk(typ reflect.Type, info *structInfo) {
+	var pk *fieldInfo
+	for _, field := range info.fields {
+		if !field.pk {
+			continue
+		}
+		if pk != nil {
+			return
+		}
+		pk = field
+	}
+	if pk == nil || pk.auto {
+		return
+	}
+	ft := typ.FieldByIndex(pk.index).Type
+	switch ft.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		pk.auto = true
+	}
+}
+
+// fieldByIndex 按索引取字段，遇到空的嵌入指针会自动分配
+func fieldByIndex(value reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value
+}
+
+// scanStruct 将当前行扫描到结构体中
+func scanStruct(rows *sql.Rows, columns []string, dest reflect.Value) error {
+	info := getStructInfo(dest.Type())
+	targets := make([]any, len(columns))
+	for i, column := range columns {
+		field, ok := info.names[strings.ToLower(column)]
+		if !ok {
+			return fmt.Errorf("字段 %s 没有映射到 %s", column, dest.Type().String())
+		}
+		targets[i] = fieldByIndex(dest, field.index).Addr().Interface()
+	}
+	return rows.Scan(targets...)
+}
+
+// scanAll 将结果集扫描到 *[]T 或 *[]*T 中
+func scanAll(rows *sql.Rows, dest any) error {
+	defer rows.Close()
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Slice {
+		return errors.New("接收对象必须是切片指针")
+	}
+	slice := value.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Pointer
+	baseType := elemType
+	if isPtr {
+		baseType = elemType.Elem()
+	}
+	if baseType.Kind() != reflect.Struct {
+		return errors.New("接收对象必须是结构体切片")
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	list := reflect.MakeSlice(slice.Type(), 0, 0)
+	for rows.Next() {
+		item := reflect.New(baseType)
+		if err = scanStruct(rows, columns, item.Elem()); err != nil {
+			return err
+		}
+		if isPtr {
+			list = reflect.Append(list, item)
+		} else {
+			list = reflect.Append(list, item.Elem())
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	slice.Set(list)
+	return nil
+}
+
+// QueryInto 查询多行并映射到结构体
+func QueryInto[T any](q Querier, query string, args ...any) ([]T, error) {
+	return QueryIntoContext[T](context.Background(), q, query, args...)
+}
+
+// QueryIntoContext 带有上下文查询多行并映射到结构体
+func QueryIntoContext[T any](ctx context.Context, q Querier, query string, args ...any) ([]T, error) {
+	rows, err := q.QueryRowsContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0)
+	if err = scanAll(rows, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// QueryRowInto 查询1行并映射到结构体，没有数据时返回 nil
+func QueryRowInto[T any](q Querier, query string, args ...any) (*T, error) {
+	return QueryRowIntoContext[T](context.Background(), q, query, args...)
+}
+
+// QueryRowIntoContext 带有上下文查询1行并映射到结构体，没有数据时返回 nil
+func QueryRowIntoContext[T any](ctx context.Context, q Querier, query string, args ...any) (*T, error) {
+	list, err := QueryIntoContext[T](ctx, q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > 0 {
+		return &list[0], nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,97 @@
+package dbs
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	Id      int       `db:"id,pk,auto"`
+	AddTime time.Time `db:"add_time"`
+}
+
+type testUser struct {
+	testBase
+	Name   string         `db:"name"`
+	Email  *string        `db:"email"`
+	Remark sql.NullString `db:"remark"`
+	Ignore string         `db:"-"`
+	Score  float64
+}
+
+func TestGetStructInfo(t *testing.T) {
+	info := getStructInfo(reflect.TypeOf(testUser{}))
+	names := make([]string, 0)
+	for _, field := range info.fields {
+		names = append(names, field.name)
+	}
+	want := []string{"id", "add_time", "name", "email", "remark", "Score"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("fields = %v, want %v", names, want)
+	}
+	id := info.names["id"]
+	if !id.pk || !id.auto || !reflect.DeepEqual(id.index, []int{0, 0}) {
+		t.Fatalf("id field = %+v", id)
+	}
+	if _, ok := info.names["score"]; !ok {
+		t.Fatal("untagged field should be matched case-insensitively")
+	}
+}
+
+type testArticle struct {
+	Id      int64  `db:"id,pk,auto"`
+	Title   string `db:"title"`
+	Summary string `db:"summary,omitempty"`
+	Views   int    `db:"views,readonly"`
+}
+
+func TestStructData(t *testing.T) {
+	item := &testArticle{Title: "hello", Views: 3}
+	value, err := structValue(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, pks := structData(value, true)
+	if !reflect.DeepEqual(data, H{"title": "hello"}) {
+		t.Fatalf("insert data = %v", data)
+	}
+	if len(pks) != 1 || pks[0].name != "id" {
+		t.Fatalf("pks = %v", pks)
+	}
+	if _, _, err = pkWhere(value, pks); err == nil {
+		t.Fatal("zero primary key should be rejected")
+	}
+	item.Id = 7
+	item.Summary = "sum"
+	data, pks = structData(value, false)
+	if !reflect.DeepEqual(data, H{"title": "hello", "summary": "sum"}) {
+		t.Fatalf("update data = %v", data)
+	}
+	where, args, err := pkWhere(value, pks)
+	if err != nil || where != "`id`=?" || !reflect.DeepEqual(args, []any{int64(7)}) {
+		t.Fatalf("where = %s %v %v", where, args, err)
+	}
+}
+
+func TestStructAutoPk(t *testing.T) {
+	type plain struct {
+		Id   uint   `db:"id,pk"`
+		Name string `db:"name"`
+	}
+	type composite struct {
+		Uid  int `db:"uid,pk"`
+		Role int `db:"role,pk"`
+	}
+	value, _ := structValue(&plain{Name: "a"})
+	data, _ := structData(value, true)
+	if !reflect.DeepEqual(data, H{"name": "a"}) {
+		t.Fatalf("single integer pk should be auto: %v", data)
+	}
+	value, _ = structValue(&composite{})
+	data, _ = structData(value, true)
+	if !reflect.DeepEqual(data, H{"uid": 0, "role": 0}) {
+		t.Fatalf("composite pk should be kept: %v", data)
+	}
+}
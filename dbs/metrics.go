@@ -0,0 +1,251 @@
+package dbs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricBuckets 耗时直方图的区间上限，单位秒
+var metricBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+	errors uint64
+}
+
+// Metrics 统计每种操作的耗时和错误次数，作为 Hook 注册到 DB
+type Metrics struct {
+	mutex sync.Mutex
+	ops   map[string]*histogram
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{ops: make(map[string]*histogram)}
+}
+
+func (m *Metrics) Before(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (m *Metrics) After(ctx context.Context, event *QueryEvent) {
+	m.Observe(event.Op, event.Duration, event.Err)
+}
+
+// Observe 记录一次操作
+func (m *Metrics) Observe(op string, duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	h, ok := m.ops[op]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(metricBuckets))}
+		m.ops[op] = h
+	}
+	seconds := duration.Seconds()
+	for i, le := range metricBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+	if err != nil {
+		h.errors++
+	}
+}
+
+// EnableMetrics 开启统计，重复调用返回同一个 Metrics
+func (db *DB) EnableMetrics() *Metrics {
+	if db.metrics == nil {
+		db.metrics = NewMetrics()
+		db.AddHook(db.metrics)
+	}
+	return db.metrics
+}
+
+func metricName(db *DB) string {
+	if db.name == "" {
+		return "main"
+	}
+	return db.name
+}
+
+func writeMetric(w *bufio.Writer, name string, typ string, help string, values func(w *bufio.Writer)) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	values(w)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// replicaStats 从库连接池的统计快照
+type replicaStats struct {
+	db      string
+	index   int
+	healthy bool
+	stats   sql.DBStats
+}
+
+// replicaMetrics 收集所有从库的连接池统计
+func replicaMetrics(list []*DB) []replicaStats {
+	items := make([]replicaStats, 0)
+	for _, db := range list {
+		if db.cluster == nil {
+			continue
+		}
+		db.cluster.mutex.RLock()
+		for i, r := range db.cluster.replicas {
+			items = append(items, replicaStats{db: metricName(db), index: i, healthy: r.healthy.Load(), stats: r.db.Stats()})
+		}
+		db.cluster.mutex.RUnlock()
+	}
+	return items
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出连接池和操作统计，从库的连接池统计使用 goyee_db_replica_ 前缀
+func WriteMetrics(out io.Writer, list ...*DB) error {
+	w := bufio.NewWriter(out)
+	list = append([]*DB(nil), list...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	poolMetrics := []struct {
+		name string
+		typ  string
+		help string
+		get  func(stats sql.DBStats) string
+	}{
+		{"max_open_connections", "gauge", "Maximum number of open connections.", func(s sql.DBStats) string { return strconv.Itoa(s.MaxOpenConnections) }},
+		{"open_connections", "gauge", "Number of established connections.", func(s sql.DBStats) string { return strconv.Itoa(s.OpenConnections) }},
+		{"in_use_connections", "gauge", "Number of connections currently in use.", func(s sql.DBStats) string { return strconv.Itoa(s.InUse) }},
+		{"idle_connections", "gauge", "Number of idle connections.", func(s sql.DBStats) string { return strconv.Itoa(s.Idle) }},
+		{"wait_count_total", "counter", "Total number of connections waited for.", func(s sql.DBStats) string { return strconv.FormatInt(s.WaitCount, 10) }},
+		{"wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.", func(s sql.DBStats) string { return formatFloat(s.WaitDuration.Seconds()) }},
+	}
+	for _, g := range poolMetrics {
+		name := "goyee_db_" + g.name
+		writeMetric(w, name, g.typ, g.help, func(w *bufio.Writer) {
+			for _, db := range list {
+				fmt.Fprintf(w, "%s{db=%q} %s\n", name, metricName(db), g.get(db.Stats()))
+			}
+		})
+	}
+	replicas := replicaMetrics(list)
+	if len(replicas) > 0 {
+		writeMetric(w, "goyee_db_replica_healthy", "gauge", "Whether the replica passed the last health check.", func(w *bufio.Writer) {
+			for _, r := range replicas {
+				healthy := 0
+				if r.healthy {
+					healthy = 1
+				}
+				fmt.Fprintf(w, "goyee_db_replica_healthy{db=%q,replica=\"%d\"} %d\n", r.db, r.index, healthy)
+			}
+		})
+		for _, g := range poolMetrics {
+			name := "goyee_db_replica_" + g.name
+			writeMetric(w, name, g.typ, "Replica: "+g.help, func(w *bufio.Writer) {
+				for _, r := range replicas {
+					fmt.Fprintf(w, "%s{db=%q,replica=\"%d\"} %s\n", name, r.db, r.index, g.get(r.stats))
+				}
+			})
+		}
+	}
+	stmtMetrics := []struct {
+		name string
+		typ  string
+		help string
+		get  func(stats StmtCacheStats) string
+	}{
+		{"goyee_db_stmt_cache_size", "gauge", "Number of cached prepared statements.", func(s StmtCacheStats) string { return strconv.Itoa(s.Size) }},
+		{"goyee_db_stmt_cache_hits_total", "counter", "Total number of prepared statement cache hits.", func(s StmtCacheStats) string { return strconv.FormatUint(s.Hits, 10) }},
+		{"goyee_db_stmt_cache_misses_total", "counter", "Total number of prepared statement cache misses.", func(s StmtCacheStats) string { return strconv.FormatUint(s.Misses, 10) }},
+		{"goyee_db_stmt_cache_evictions_total", "counter", "Total number of evicted prepared statements.", func(s StmtCacheStats) string { return strconv.FormatUint(s.Evictions, 10) }},
+	}
+	cached := make([]*DB, 0)
+	for _, db := range list {
+		if db.stmts != nil {
+			cached = append(cached, db)
+		}
+	}
+	if len(cached) > 0 {
+		for _, g := range stmtMetrics {
+			writeMetric(w, g.name, g.typ, g.help, func(w *bufio.Writer) {
+				for _, db := range cached {
+					fmt.Fprintf(w, "%s{db=%q} %s\n", g.name, metricName(db), g.get(db.StmtCacheStats()))
+				}
+			})
+		}
+	}
+	type opItem struct {
+		db string
+		op string
+		h  histogram
+	}
+	items := make([]opItem, 0)
+	for _, db := range list {
+		if db.metrics == nil {
+			continue
+		}
+		db.metrics.mutex.Lock()
+		for op, h := range db.metrics.ops {
+			temp := *h
+			temp.counts = append([]uint64(nil), h.counts...)
+			items = append(items, opItem{db: metricName(db), op: op, h: temp})
+		}
+		db.metrics.mutex.Unlock()
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].db != items[j].db {
+			return items[i].db < items[j].db
+		}
+		return items[i].op < items[j].op
+	})
+	if len(items) > 0 {
+		writeMetric(w, "goyee_db_operation_duration_seconds", "histogram", "Duration of database operations.", func(w *bufio.Writer) {
+			for _, item := range items {
+				for i, le := range metricBuckets {
+					fmt.Fprintf(w, "goyee_db_operation_duration_seconds_bucket{db=%q,op=%q,le=%q} %d\n", item.db, item.op, formatFloat(le), item.h.counts[i])
+				}
+				fmt.Fprintf(w, "goyee_db_operation_duration_seconds_bucket{db=%q,op=%q,le=\"+Inf\"} %d\n", item.db, item.op, item.h.count)
+				fmt.Fprintf(w, "goyee_db_operation_duration_seconds_sum{db=%q,op=%q} %s\n", item.db, item.op, formatFloat(item.h.sum))
+				fmt.Fprintf(w, "goyee_db_operation_duration_seconds_count{db=%q,op=%q} %d\n", item.db, item.op, item.h.count)
+			}
+		})
+		writeMetric(w, "goyee_db_operation_errors_total", "counter", "Number of failed database operations.", func(w *bufio.Writer) {
+			for _, item := range items {
+				fmt.Fprintf(w, "goyee_db_operation_errors_total{db=%q,op=%q} %d\n", item.db, item.op, item.h.errors)
+			}
+		})
+	}
+	return w.Flush()
+}
+
+// MetricsHandler 输出所有已注册连接统计的 http.Handler
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbMutex.Lock()
+		list := make([]*DB, 0, len(dbMap))
+		for _, db := range dbMap {
+			list = append(list, db)
+		}
+		dbMutex.Unlock()
+		buf := &bytes.Buffer{}
+		if err := WriteMetrics(buf, list...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
@@ -0,0 +1,113 @@
+package dbs
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	sqlDb, err := sql.Open("mysql", "root@tcp(127.0.0.1:3306)/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicaDb, err := sql.Open("mysql", "root@tcp(127.0.0.1:3307)/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{DB: sqlDb, name: "orders"}
+	db.AddReplica(replicaDb, 1)
+	defer db.Close()
+	other := &DB{DB: sqlDb, name: "main"}
+	metrics := db.EnableMetrics()
+	db.SetStmtCache(8)
+	metrics.Observe("query", 3*time.Millisecond, nil)
+	metrics.Observe("query", 2*time.Second, errors.New("failed"))
+	out := &strings.Builder{}
+	list := []*DB{db, other}
+	if err = WriteMetrics(out, list...); err != nil {
+		t.Fatal(err)
+	}
+	if list[0] != db {
+		t.Fatal("WriteMetrics should not reorder the caller's slice")
+	}
+	text := out.String()
+	for _, want := range []string{
+		`goyee_db_open_connections{db="orders"} 0`,
+		`goyee_db_operation_duration_seconds_bucket{db="orders",op="query",le="0.005"} 1`,
+		`goyee_db_operation_duration_seconds_bucket{db="orders",op="query",le="+Inf"} 2`,
+		`goyee_db_operation_errors_total{db="orders",op="query"} 1`,
+		`goyee_db_stmt_cache_hits_total{db="orders"} 0`,
+		`goyee_db_replica_healthy{db="orders",replica="0"} 1`,
+		`goyee_db_replica_open_connections{db="orders",replica="0"} 0`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %s in\n%s", want, text)
+		}
+	}
+}
+
+func TestStmtCache(t *testing.T) {
+	cache := newStmtCache(2)
+	keys := []stmtKey{{query: "a"}, {query: "b"}, {query: "c"}}
+	for _, key := range keys[:2] {
+		if cache.get(key) != nil {
+			t.Fatal("empty cache should miss")
+		}
+		entry, closed := cache.add(key, nil)
+		if len(closed) != 0 {
+			t.Fatal("nothing should be closed")
+		}
+		cache.release(entry)
+	}
+	// 访问 a 后 b 成为最久未使用的语句
+	cache.release(cache.get(keys[0]))
+	entry, closed := cache.add(keys[2], nil)
+	if len(closed) != 1 {
+		t.Fatalf("closed = %d", len(closed))
+	}
+	if _, ok := cache.items[keys[1]]; ok {
+		t.Fatal("b should be evicted")
+	}
+	if entry.refs != 1 {
+		t.Fatalf("refs = %d", entry.refs)
+	}
+	stats := cache.stats()
+	if stats != (StmtCacheStats{Size: 2, Hits: 1, Misses: 2, Evictions: 1}) {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestTxStmtCache(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	db.SetStmtCache(8)
+	defer db.Close()
+	query := "update user set name=? where id=?"
+	for i := 0; i < 2; i++ {
+		err := db.Transaction(func(tx *Tx) error {
+			_, err := tx.Exec(query, "a", i)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := db.StmtCacheStats()
+	if stats.Size != 1 || stats.Misses != 1 || stats.Hits != 1 {
+		t.Fatalf("transaction statements should be cached: %+v", stats)
+	}
+	db.SetMaxOpenConns(1)
+	err := db.Transaction(func(tx *Tx) error {
+		_, err := tx.Exec("delete from user where id=?", 1)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats = db.StmtCacheStats(); stats.Size != 1 {
+		t.Fatalf("full pool should not prepare: %+v", stats)
+	}
+}
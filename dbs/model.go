@@ -0,0 +1,245 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// writer DB 和 Tx 共有的写入方法
+type writer interface {
+	InsertContext(ctx context.Context, table string, data H) (sql.Result, error)
+	ReplaceContext(ctx context.Context, table string, data H) (sql.Result, error)
+	UpdateContext(ctx context.Context, table string, data H, where any, args ...any) (sql.Result, error)
+	DeleteContext(ctx context.Context, table string, where any, args ...any) (sql.Result, error)
+}
+
+// structValue 取得结构体的值
+func structValue(v any) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return value, errors.New("结构体不能为 nil")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return value, errors.New("数据必须是结构体")
+	}
+	return value, nil
+}
+
+// fieldValue 读取字段值，嵌入的空指针返回 false
+func fieldValue(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return value, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, true
+}
+
+// structData 将结构体转为写入用的数据集，insert 为 true 时跳过零值自增主键
+func structData(value reflect.Value, insert bool) (H, []*fieldInfo) {
+	info := getStructInfo(value.Type())
+	data := make(H)
+	pks := make([]*fieldInfo, 0)
+	for _, field := range info.fields {
+		if field.pk {
+			pks = append(pks, field)
+		}
+		if field.readonly {
+			continue
+		}
+		fv, ok := fieldValue(value, field.index)
+		if !ok {
+			continue
+		}
+		if fv.IsZero() && (field.omitempty || (insert && field.auto)) {
+			continue
+		}
+		if !insert && field.pk {
+			continue
+		}
+		data[field.name] = fv.Interface()
+	}
+	return data, pks
+}
+
+// pkWhere 生成主键查询条件
+func pkWhere(value reflect.Value, pks []*fieldInfo) (string, []any, error) {
+	if len(pks) == 0 {
+		return "", nil, errors.New("结构体没有设置 pk 主键字段")
+	}
+	items := make([]string, 0, len(pks))
+	args := make([]any, 0, len(pks))
+	for _, field := range pks {
+		fv, ok := fieldValue(value, field.index)
+		if !ok || fv.IsZero() {
+			return "", nil, errors.New("主键 " + field.name + " 没有值")
+		}
+		items = append(items, QuoteIdent(field.name)+"=?")
+		args = append(args, fv.Interface())
+	}
+	return strings.Join(items, " and "), args, nil
+}
+
+// setAutoId 将自增ID回写到结构体
+func setAutoId(v any, res sql.Result) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return
+	}
+	value = value.Elem()
+	for _, field := range getStructInfo(value.Type()).fields {
+		if !field.auto {
+			continue
+		}
+		fv := fieldByIndex(value, field.index)
+		if !fv.IsZero() || !fv.CanSet() {
+			return
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			return
+		}
+		switch fv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv.SetInt(lastId)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fv.SetUint(uint64(lastId))
+		}
+		return
+	}
+}
+
+func insertStruct(ctx context.Context, w writer, table string, v any, replace bool) (sql.Result, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := structData(value, true)
+	var res sql.Result
+	if replace {
+		res, err = w.ReplaceContext(ctx, table, data)
+	} else {
+		res, err = w.InsertContext(ctx, table, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	setAutoId(v, res)
+	return res, nil
+}
+
+func updateStruct(ctx context.Context, w writer, table string, v any) (sql.Result, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	data, pks := structData(value, false)
+	where, args, err := pkWhere(value, pks)
+	if err != nil {
+		return nil, err
+	}
+	return w.UpdateContext(ctx, table, data, where, args...)
+}
+
+func deleteStruct(ctx context.Context, w writer, table string, v any) (sql.Result, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	_, pks := structData(value, false)
+	where, args, err := pkWhere(value, pks)
+	if err != nil {
+		return nil, err
+	}
+	return w.DeleteContext(ctx, table, where, args...)
+}
+
+// InsertStruct 按 db 标签插入结构体，传入指针时会回写自增ID
+func (db *DB) InsertStruct(table string, v any) (sql.Result, error) {
+	return db.InsertStructContext(context.Background(), table, v)
+}
+
+// InsertStructContext 带有上下文按 db 标签插入结构体，传入指针时会回写自增ID
+func (db *DB) InsertStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return insertStruct(ctx, db, table, v, false)
+}
+
+// ReplaceStruct 按 db 标签替换结构体
+func (db *DB) ReplaceStruct(table string, v any) (sql.Result, error) {
+	return db.ReplaceStructContext(context.Background(), table, v)
+}
+
+// ReplaceStructContext 带有上下文按 db 标签替换结构体
+func (db *DB) ReplaceStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return insertStruct(ctx, db, table, v, true)
+}
+
+// UpdateStruct 按 pk 主键更新结构体
+func (db *DB) UpdateStruct(table string, v any) (sql.Result, error) {
+	return db.UpdateStructContext(context.Background(), table, v)
+}
+
+// UpdateStructContext 带有上下文按 pk 主键更新结构体
+func (db *DB) UpdateStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return updateStruct(ctx, db, table, v)
+}
+
+// DeleteStruct 按 pk 主键删除结构体对应的数据
+func (db *DB) DeleteStruct(table string, v any) (sql.Result, error) {
+	return db.DeleteStructContext(context.Background(), table, v)
+}
+
+// DeleteStructContext 带有上下文按 pk 主键删除结构体对应的数据
+func (db *DB) DeleteStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return deleteStruct(ctx, db, table, v)
+}
+
+// InsertStruct 按 db 标签插入结构体，传入指针时会回写自增ID
+func (tx *Tx) InsertStruct(table string, v any) (sql.Result, error) {
+	return tx.InsertStructContext(context.Background(), table, v)
+}
+
+// InsertStructContext 带有上下文按 db 标签插入结构体，传入指针时会回写自增ID
+func (tx *Tx) InsertStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return insertStruct(ctx, tx, table, v, false)
+}
+
+// ReplaceStruct 按 db 标签替换结构体
+func (tx *Tx) ReplaceStruct(table string, v any) (sql.Result, error) {
+	return tx.ReplaceStructContext(context.Background(), table, v)
+}
+
+// ReplaceStructContext 带有上下文按 db 标签替换结构体
+func (tx *Tx) ReplaceStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return insertStruct(ctx, tx, table, v, true)
+}
+
+// UpdateStruct 按 pk 主键更新结构体
+func (tx *Tx) UpdateStruct(table string, v any) (sql.Result, error) {
+	return tx.UpdateStructContext(context.Background(), table, v)
+}
+
+// UpdateStructContext 带有上下文按 pk 主键更新结构体
+func (tx *Tx) UpdateStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return updateStruct(ctx, tx, table, v)
+}
+
+// DeleteStruct 按 pk 主键删除结构体对应的数据
+func (tx *Tx) DeleteStruct(table string, v any) (sql.Result, error) {
+	return tx.DeleteStructContext(context.Background(), table, v)
+}
+
+// DeleteStructContext 带有上下文按 pk 主键删除结构体对应的数据
+func (tx *Tx) DeleteStructContext(ctx context.Context, table string, v any) (sql.Result, error) {
+	return deleteStruct(ctx, tx, table, v)
+}
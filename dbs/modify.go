@@ -0,0 +1,194 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+/*
+*
+允许在没有查询条件时执行 Update 和 Delete
+*/
+func (slt *Selector) AllowFull() *Selector {
+	slt.allowFull = true
+	return slt
+}
+
+// modifyParts 生成更新和删除语句共用的表名、连接、条件、排序和限制
+func (slt *Selector) modifyParts() (table string, where *Frame, tail *Frame, err error) {
+	if slt.table == "" || len(slt.args) > 0 || strings.HasPrefix(slt.table, "(") {
+		return "", nil, nil, errors.New("更新，不支持子查询作为目标表")
+	}
+	if slt.groups != nil || slt.having != nil || slt.unions != nil {
+		return "", nil, nil, errors.New("更新，不支持分组、having 或联合查询")
+	}
+	reg1, _ := regexp.Compile(`\s+`)
+	table = slt.table
+	if !reg1.MatchString(table) {
+		table = QuoteIdent(table)
+	}
+	where = NewFrame("", "where")
+	frame := slt.GetFrame()
+	if frame.Sql != "" {
+		reg2, _ := regexp.Compile(`(?i)^(or|and)\s+`)
+		where.Sql = "where " + reg2.ReplaceAllString(frame.Sql, "")
+		where.Args = frame.Args
+	} else if !slt.allowFull {
+		return "", nil, nil, errors.New("更新，缺少查询条件，如需全表操作请调用 AllowFull")
+	}
+	tail = NewFrame("", "tail")
+	hasOrder := slt.orders != nil && slt.orders.Sql != ""
+	if slt.joins != nil && (hasOrder || slt.limit != "") {
+		return "", nil, nil, errors.New("更新，多表操作不支持 order by 和 limit")
+	}
+	if hasOrder {
+		tail.Add(slt.orders.Sql, slt.orders.Args...)
+	}
+	if slt.limit != "" {
+		limit := strings.TrimSpace(strings.TrimPrefix(slt.limit, "limit"))
+		if offset, size, found := strings.Cut(limit, ","); found {
+			if strings.TrimSpace(offset) != "0" {
+				return "", nil, nil, errors.New("更新，limit 不支持偏移量")
+			}
+			limit = strings.TrimSpace(size)
+		}
+		tail.Add("limit " + limit)
+	}
+	return table, where, tail, nil
+}
+
+/*
+*
+创建按查询条件更新的语句
+*/
+func (slt *Selector) BuildUpdate(data H) (*Frame, error) {
+	table, where, tail, err := slt.modifyParts()
+	if err != nil {
+		return nil, err
+	}
+	names, values := buildSet(data)
+	if len(names) == 0 {
+		return nil, errors.New("更新，没有相应的数据")
+	}
+	frame := NewFrame("update "+table, "sql")
+	if slt.joins != nil && slt.joins.Sql != "" {
+		frame.Add(slt.joins.Sql, slt.joins.Args...)
+	}
+	frame.Add("set "+strings.Join(names, ","), values...)
+	if where.Sql != "" {
+		frame.Add(where.Sql, where.Args...)
+	}
+	if tail.Sql != "" {
+		frame.Add(tail.Sql, tail.Args...)
+	}
+	return frame, nil
+}
+
+/*
+*
+创建按查询条件删除的语句，多表删除时只删除主表的数据
+*/
+func (slt *Selector) BuildDelete() (*Frame, error) {
+	table, where, tail, err := slt.modifyParts()
+	if err != nil {
+		return nil, err
+	}
+	frame := NewFrame("delete from "+table, "sql")
+	if slt.joins != nil && slt.joins.Sql != "" {
+		parts := strings.Fields(table)
+		target := parts[len(parts)-1]
+		if len(parts) == 1 {
+			target = table
+		}
+		frame = NewFrame("delete "+target+" from "+table, "sql")
+		frame.Add(slt.joins.Sql, slt.joins.Args...)
+	}
+	if where.Sql != "" {
+		frame.Add(where.Sql, where.Args...)
+	}
+	if tail.Sql != "" {
+		frame.Add(tail.Sql, tail.Args...)
+	}
+	return frame, nil
+}
+
+// targetTable 返回更新和删除的目标表名，子查询返回空
+func (slt *Selector) targetTable() string {
+	fields := strings.Fields(slt.table)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "(") {
+		return ""
+	}
+	return fields[0]
+}
+
+// exec 执行更新或删除，并使查询涉及的表的缓存失效
+func (slt *Selector) exec(ctx context.Context, frame *Frame) (sql.Result, error) {
+	if err := slt.Err(); err != nil {
+		return nil, err
+	}
+	res, err := slt.db.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range slt.tags {
+		slt.db.invalidate(table)
+	}
+	return res, nil
+}
+
+/*
+*
+按查询条件更新数据
+*/
+func (slt *Selector) Update(data H) (sql.Result, error) {
+	return slt.UpdateContext(slt.context(), data)
+}
+
+/*
+*
+带有上下文按查询条件更新数据
+*/
+func (slt *Selector) UpdateContext(ctx context.Context, data H) (sql.Result, error) {
+	if table := slt.targetTable(); table != "" {
+		var err error
+		if slt.joins != nil {
+			err = slt.db.checkWrite(table, data)
+		} else {
+			data, err = slt.db.prepareWrite(ctx, table, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	frame, err := slt.BuildUpdate(data)
+	if err != nil {
+		return nil, err
+	}
+	return slt.exec(ctx, frame)
+}
+
+/*
+*
+按查询条件删除数据
+*/
+func (slt *Selector) Delete() (sql.Result, error) {
+	return slt.DeleteContext(slt.context())
+}
+
+/*
+*
+带有上下文按查询条件删除数据
+*/
+func (slt *Selector) DeleteContext(ctx context.Context) (sql.Result, error) {
+	frame, err := slt.BuildDelete()
+	if err != nil {
+		return nil, err
+	}
+	if err = slt.db.checkWrite(slt.targetTable(), nil); err != nil {
+		return nil, err
+	}
+	return slt.exec(ctx, frame)
+}
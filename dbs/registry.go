@@ -0,0 +1,149 @@
+package dbs
+
+import (
+	"database/sql"
+	"github.com/wj008/goyee/config"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	dbMutex sync.Mutex
+	dbMap   = make(map[string]*DB)
+)
+
+// configKey 获取连接的配置项名称，主库为 db_host，命名连接为 db_{name}_host
+func configKey(name string, key string) string {
+	if name == "" {
+		return "db_" + key
+	}
+	return "db_" + name + "_" + key
+}
+
+// cfgString 读取连接配置，命名连接未设置时使用主库配置
+func cfgString(name string, key string, def string) string {
+	return config.String(configKey(name, key), config.String("db_"+key, def))
+}
+
+// cfgInt 读取连接配置，命名连接未设置时使用主库配置
+func cfgInt(name string, key string, def int) int {
+	return config.Int(configKey(name, key), config.Int("db_"+key, def))
+}
+
+// Db 获取主数据库
+func Db() (*DB, error) {
+	return Use("")
+}
+
+// Use 获取指定名称的数据库连接，空名称为主数据库
+// 连接在锁外打开，避免一个不可用的库阻塞其他连接的获取
+func Use(name string) (*DB, error) {
+	dbMutex.Lock()
+	db, ok := dbMap[name]
+	dbMutex.Unlock()
+	if ok {
+		return db, nil
+	}
+	db, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	dbMutex.Lock()
+	if exists, ok := dbMap[name]; ok {
+		// 其他调用已经打开了同名连接，使用已注册的连接
+		dbMutex.Unlock()
+		db.Close()
+		return exists, nil
+	}
+	dbMap[name] = db
+	dbMutex.Unlock()
+	return db, nil
+}
+
+// Register 注册已经打开的数据库连接，替换同名连接时会关闭旧连接
+func Register(name string, sqlDb *sql.DB, prefix string) *DB {
+	db := &DB{DB: sqlDb, name: name, prefix: prefix}
+	dbMutex.Lock()
+	old, ok := dbMap[name]
+	dbMap[name] = db
+	dbMutex.Unlock()
+	if ok && old.DB != sqlDb {
+		old.Close()
+	}
+	return db
+}
+
+// Close 关闭并移除指定名称的数据库连接
+func Close(name string) error {
+	dbMutex.Lock()
+	db, ok := dbMap[name]
+	delete(dbMap, name)
+	dbMutex.Unlock()
+	if !ok {
+		return nil
+	}
+	return db.Close()
+}
+
+// Name 连接名称
+func (db *DB) Name() string {
+	return db.name
+}
+
+// Prefix 表前缀
+func (db *DB) Prefix() string {
+	return db.prefix
+}
+
+// open 按配置打开数据库连接
+func open(name string) (*DB, error) {
+	userName := cfgString(name, "username", "root")
+	password := cfgString(name, "password", "")
+	host := cfgString(name, "host", "127.0.0.1")
+	port := cfgString(name, "port", "3306")
+	dbName := cfgString(name, "dbname", "test")
+	charset := cfgString(name, "charset", "utf8")
+	maxLifetime := cfgInt(name, "max_lifetime", 100)
+	poolSize := cfgInt(name, "pool_size", 1)
+	maxIdle := cfgInt(name, "max_idle", 1)
+	prefix := cfgString(name, "prefix", "")
+	dsn := func(host string, port string) string {
+		return strings.Join([]string{userName, ":", password, "@tcp(", host, ":", port, ")/", dbName, "?charset=", charset, "&parseTime=True"}, "")
+	}
+	db, err := sql.Open("mysql", dsn(host, port))
+	if err != nil {
+		return nil, err
+	}
+	//设置数据库超时时间
+	db.SetConnMaxLifetime(time.Duration(maxLifetime) * time.Second)
+	db.SetMaxOpenConns(poolSize)
+	//设置上数据库最大闲置连接数
+	db.SetMaxIdleConns(maxIdle)
+	//验证连接
+	if err = db.Ping(); err != nil {
+		log.Println("打开数据库失败", name, err.Error())
+		db.Close()
+		return nil, err
+	}
+	mdb := &DB{DB: db, name: name, prefix: prefix}
+	openReplicas(mdb, name, dsn)
+	if config.Bool(configKey(name, "log"), false) {
+		mdb.AddHook(NewLogHook(name))
+	}
+	if config.Bool(configKey(name, "metrics"), false) {
+		mdb.EnableMetrics()
+	}
+	if config.Bool(configKey(name, "strict"), false) {
+		mdb.SetStrict(true)
+	}
+	mdb.SetStmtCache(config.Int(configKey(name, "stmt_cache"), 0))
+	switch config.String(configKey(name, "schema_mode"), "") {
+	case "drop":
+		mdb.SetSchemaMode(SchemaDrop)
+	case "reject":
+		mdb.SetSchemaMode(SchemaReject)
+	}
+	return mdb, nil
+}
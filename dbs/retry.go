@@ -0,0 +1,97 @@
+package dbs
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"math/rand"
+	"time"
+)
+
+const (
+	ErrLockWaitTimeout uint16 = 1205 // 锁等待超时
+	ErrDeadlock        uint16 = 1213 // 死锁
+)
+
+// RetryOptions 事务重试选项
+type RetryOptions struct {
+	MaxAttempts int           // 最多执行次数，默认 3
+	BaseDelay   time.Duration // 首次重试前的等待时间，默认 50ms
+	MaxDelay    time.Duration // 最长等待时间，默认 2s
+	Codes       []uint16      // 可重试的 MySQL 错误码，默认死锁和锁等待超时
+}
+
+func (opts *RetryOptions) withDefaults() RetryOptions {
+	temp := RetryOptions{}
+	if opts != nil {
+		temp = *opts
+	}
+	if temp.MaxAttempts < 1 {
+		temp.MaxAttempts = 3
+	}
+	if temp.BaseDelay <= 0 {
+		temp.BaseDelay = 50 * time.Millisecond
+	}
+	if temp.MaxDelay <= 0 {
+		temp.MaxDelay = 2 * time.Second
+	}
+	if len(temp.Codes) == 0 {
+		temp.Codes = []uint16{ErrDeadlock, ErrLockWaitTimeout}
+	}
+	return temp
+}
+
+// IsRetryable 判断错误是否为可重试的 MySQL 错误，未指定错误码时判断死锁和锁等待超时
+func IsRetryable(err error, codes ...uint16) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	if len(codes) == 0 {
+		codes = []uint16{ErrDeadlock, ErrLockWaitTimeout}
+	}
+	for _, code := range codes {
+		if myErr.Number == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 计算第 attempt 次重试前的等待时间，指数增长并带有随机抖动
+func (opts *RetryOptions) backoff(attempt int) time.Duration {
+	delay := opts.BaseDelay
+	for i := 1; i < attempt && delay < opts.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > opts.MaxDelay {
+		delay = opts.MaxDelay
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// TransactionWithRetry 执行事务，遇到死锁等可重试错误时重新执行 fn，返回执行次数
+func (db *DB) TransactionWithRetry(opts *RetryOptions, fn func(*Tx) error) (int, error) {
+	return db.TransactionWithRetryContext(context.Background(), opts, fn)
+}
+
+// TransactionWithRetryContext 带有上下文执行可重试的事务，返回执行次数
+func (db *DB) TransactionWithRetryContext(ctx context.Context, opts *RetryOptions, fn func(*Tx) error) (int, error) {
+	temp := opts.withDefaults()
+	attempt := 0
+	for {
+		attempt++
+		err := db.TransactionContext(ctx, fn)
+		if err == nil || attempt >= temp.MaxAttempts || !IsRetryable(err, temp.Codes...) {
+			return attempt, err
+		}
+		timer := time.NewTimer(temp.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
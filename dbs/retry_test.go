@@ -0,0 +1,97 @@
+package dbs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	if !IsRetryable(fmt.Errorf("wrap: %w", deadlock)) {
+		t.Fatal("wrapped deadlock should be retryable")
+	}
+	if IsRetryable(&mysql.MySQLError{Number: 1062}) {
+		t.Fatal("duplicate entry should not be retryable")
+	}
+	if !IsRetryable(&mysql.MySQLError{Number: 1062}, 1062) {
+		t.Fatal("custom codes should be honored")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	opts := (&RetryOptions{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}).withDefaults()
+	for attempt := 1; attempt <= 6; attempt++ {
+		delay := opts.backoff(attempt)
+		if delay > opts.MaxDelay || delay < opts.BaseDelay/2 {
+			t.Fatalf("attempt %d delay %s out of range", attempt, delay)
+		}
+	}
+}
+
+func TestTransactionWithRetry(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	opts := &RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	errs := []error{&mysql.MySQLError{Number: 1213}, &mysql.MySQLError{Number: 1205}}
+	calls := 0
+	attempts, err := db.TransactionWithRetry(opts, func(tx *Tx) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 || calls != 3 {
+		t.Fatalf("attempts = %d, calls = %d, err = %v", attempts, calls, err)
+	}
+	if n := countStatements(srv, "ROLLBACK"); n != 2 {
+		t.Fatalf("rollbacks = %d", n)
+	}
+	calls = 0
+	attempts, err = db.TransactionWithRetry(opts, func(tx *Tx) error {
+		calls++
+		return &mysql.MySQLError{Number: 1213}
+	})
+	if !IsRetryable(err) || attempts != 4 || calls != 4 {
+		t.Fatalf("max attempts: attempts = %d, calls = %d, err = %v", attempts, calls, err)
+	}
+	fail := errors.New("fail")
+	calls = 0
+	attempts, err = db.TransactionWithRetry(opts, func(tx *Tx) error {
+		calls++
+		return fail
+	})
+	if err != fail || attempts != 1 || calls != 1 {
+		t.Fatalf("non retryable: attempts = %d, calls = %d, err = %v", attempts, calls, err)
+	}
+}
+
+func TestTransactionWithRetryCancel(t *testing.T) {
+	db := newFakeDB(&fakeServer{})
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := &RetryOptions{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Minute}
+	calls := 0
+	attempts, err := db.TransactionWithRetryContext(ctx, opts, func(tx *Tx) error {
+		calls++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return &mysql.MySQLError{Number: 1213}
+	})
+	if err != context.Canceled || attempts != 1 || calls != 1 {
+		t.Fatalf("attempts = %d, calls = %d, err = %v", attempts, calls, err)
+	}
+}
+
+// countStatements 统计执行过的指定语句次数
+func countStatements(srv *fakeServer, query string) int {
+	count := 0
+	for _, item := range srv.statements() {
+		if item == query {
+			count++
+		}
+	}
+	return count
+}
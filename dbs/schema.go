@@ -0,0 +1,259 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Column 字段结构
+type Column struct {
+	Name     string
+	Type     string  // 完整类型，如 int(11) unsigned
+	DataType string  // 基础类型，如 int、varchar
+	Nullable bool    // 是否允许 NULL
+	Default  *string // 默认值，没有默认值时为 nil
+	Key      string  // PRI、UNI、MUL
+	Extra    string  // 如 auto_increment
+	Comment  string
+}
+
+// Index 索引结构
+type Index struct {
+	Name    string
+	Columns []string // 按索引中的顺序排列
+	Unique  bool
+	Primary bool
+}
+
+// SchemaMode 写入时对未知字段的处理方式
+type SchemaMode int
+
+const (
+	SchemaOff    SchemaMode = 0 // 不检查，兼容原有行为
+	SchemaDrop   SchemaMode = 1 // 丢弃表中不存在的字段
+	SchemaReject SchemaMode = 2 // 存在未知字段时返回错误
+)
+
+// schemaCache 表字段快照，首次使用时读取
+type schemaCache struct {
+	mutex  sync.Mutex
+	tables map[string]map[string]bool
+}
+
+// tableName 替换表前缀并去掉反引号，表名可以带有库名，如 shop.@pf_user
+func (db *DB) tableName(table string) string {
+	table = strings.ReplaceAll(strings.TrimSpace(table), "`", "")
+	return strings.Replace(table, "@pf_", db.prefix, -1)
+}
+
+// tableWhere 生成按表名查询 information_schema 的条件，没有库名时使用当前库
+func tableWhere(name string) (string, []any) {
+	if i := strings.Index(name, "."); i >= 0 {
+		return "table_schema=? and table_name=?", []any{name[:i], name[i+1:]}
+	}
+	return "table_schema=database() and table_name=?", []any{name}
+}
+
+// Tables 获取当前库中属于该连接的表，设置了表前缀时只返回带有前缀的表，并以 @pf_ 形式返回
+func (db *DB) Tables() ([]string, error) {
+	return db.TablesContext(context.Background())
+}
+
+// TablesContext 带有上下文获取当前库中属于该连接的表
+func (db *DB) TablesContext(ctx context.Context) ([]string, error) {
+	rows, err := db.QueryRowsContext(ForcePrimary(ctx), "select table_name from information_schema.tables where table_schema=database() and table_type='BASE TABLE' order by table_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if db.prefix != "" {
+			if !strings.HasPrefix(name, db.prefix) {
+				continue
+			}
+			name = "@pf_" + name[len(db.prefix):]
+		}
+		list = append(list, name)
+	}
+	return list, rows.Err()
+}
+
+// Columns 获取表的字段，表名可以使用 @pf_ 前缀
+func (db *DB) Columns(table string) ([]Column, error) {
+	return db.ColumnsContext(context.Background(), table)
+}
+
+// ColumnsContext 带有上下文获取表的字段
+func (db *DB) ColumnsContext(ctx context.Context, table string) ([]Column, error) {
+	return loadColumns(ForcePrimary(ctx), db, db.tableName(table))
+}
+
+// loadColumns 从 information_schema 读取字段
+func loadColumns(ctx context.Context, q Querier, table string) ([]Column, error) {
+	where, args := tableWhere(table)
+	rows, err := q.QueryRowsContext(ctx, "select column_name,column_type,data_type,is_nullable,column_default,column_key,extra,column_comment from information_schema.columns where "+where+" order by ordinal_position", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]Column, 0)
+	for rows.Next() {
+		var col Column
+		var nullable string
+		var def sql.NullString
+		if err = rows.Scan(&col.Name, &col.Type, &col.DataType, &nullable, &def, &col.Key, &col.Extra, &col.Comment); err != nil {
+			return nil, err
+		}
+		col.Nullable = nullable == "YES"
+		if def.Valid {
+			col.Default = &def.String
+		}
+		list = append(list, col)
+	}
+	return list, rows.Err()
+}
+
+// Indexes 获取表的索引，主键排在最前面
+func (db *DB) Indexes(table string) ([]Index, error) {
+	return db.IndexesContext(context.Background(), table)
+}
+
+// IndexesContext 带有上下文获取表的索引
+func (db *DB) IndexesContext(ctx context.Context, table string) ([]Index, error) {
+	where, args := tableWhere(db.tableName(table))
+	rows, err := db.QueryRowsContext(ForcePrimary(ctx), "select index_name,column_name,non_unique from information_schema.statistics where "+where+" order by index_name,seq_in_index", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]Index, 0)
+	indexes := make(map[string]int)
+	for rows.Next() {
+		var name, column string
+		var nonUnique int
+		if err = rows.Scan(&name, &column, &nonUnique); err != nil {
+			return nil, err
+		}
+		i, ok := indexes[name]
+		if !ok {
+			i = len(list)
+			indexes[name] = i
+			list = append(list, Index{Name: name, Unique: nonUnique == 0, Primary: name == "PRIMARY"})
+		}
+		list[i].Columns = append(list[i].Columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Primary && !list[j].Primary
+	})
+	return list, nil
+}
+
+// PrimaryKey 获取表的主键字段，没有主键时返回空
+func (db *DB) PrimaryKey(table string) ([]string, error) {
+	indexes, err := db.Indexes(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) > 0 && indexes[0].Primary {
+		return indexes[0].Columns, nil
+	}
+	return nil, nil
+}
+
+/*
+*
+设置写入时对未知字段的处理方式，表结构会被缓存，结构变更后需调用 RefreshSchema
+*/
+func (db *DB) SetSchemaMode(mode SchemaMode) *DB {
+	db.schemaMode = mode
+	return db
+}
+
+// RefreshSchema 清除缓存的表结构
+func (db *DB) RefreshSchema() {
+	db.schema.mutex.Lock()
+	db.schema.tables = nil
+	db.schema.mutex.Unlock()
+}
+
+// columnSet 获取缓存的表字段，缓存中没有时通过 q 读取
+func (db *DB) columnSet(ctx context.Context, q Querier, table string) (map[string]bool, error) {
+	name := db.tableName(table)
+	db.schema.mutex.Lock()
+	set, ok := db.schema.tables[name]
+	db.schema.mutex.Unlock()
+	if ok {
+		return set, nil
+	}
+	columns, err := loadColumns(ctx, q, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("表 " + name + " 不存在")
+	}
+	set = make(map[string]bool, len(columns))
+	for _, col := range columns {
+		set[strings.ToLower(col.Name)] = true
+	}
+	db.schema.mutex.Lock()
+	if db.schema.tables == nil {
+		db.schema.tables = make(map[string]map[string]bool)
+	}
+	db.schema.tables[name] = set
+	db.schema.mutex.Unlock()
+	return set, nil
+}
+
+// filterData 按字段集合过滤或拒绝未知字段，带表别名的字段不做检查
+func filterData(mode SchemaMode, table string, set map[string]bool, data H) (H, error) {
+	temp := make(H, len(data))
+	for key, value := range data {
+		if strings.Contains(key, ".") || set[strings.ToLower(key)] {
+			temp[key] = value
+			continue
+		}
+		if mode == SchemaReject {
+			return nil, fmt.Errorf("表 %s 没有字段 %s", table, key)
+		}
+	}
+	return temp, nil
+}
+
+// prepareWrite 写入前校验表名和字段，并按结构模式处理未知字段
+func (db *DB) prepareWrite(ctx context.Context, table string, data H) (H, error) {
+	return db.prepare(ForcePrimary(ctx), db, table, data)
+}
+
+// prepareWrite 使用所属连接的设置处理写入的字段，表结构在事务内读取
+func (tx *Tx) prepareWrite(ctx context.Context, table string, data H) (H, error) {
+	return tx.db.prepare(ctx, tx, table, data)
+}
+
+// prepare 校验并处理写入的字段，q 用于读取未缓存的表结构
+func (db *DB) prepare(ctx context.Context, q Querier, table string, data H) (H, error) {
+	if err := db.checkWrite(table, data); err != nil {
+		return nil, err
+	}
+	if db.schemaMode == SchemaOff || data == nil {
+		return data, nil
+	}
+	set, err := db.columnSet(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+	return filterData(db.schemaMode, table, set, data)
+}
@@ -1,9 +1,12 @@
 package dbs
 
 import (
+	"context"
+	"errors"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type PageInfo struct {
@@ -18,6 +21,7 @@ type Selector struct {
 	*PageInfo
 	db     *DB
 	table  string
+	alias  string
 	limit  string
 	fields *Frame
 	orders *Frame
@@ -25,9 +29,24 @@ type Selector struct {
 	having *Condition
 	joins  *Frame
 	unions []*Frame
+	ctx    context.Context
+	args   []any
+	subs   int
+	err    error // 构建查询时的错误，执行时返回
+
+	allowFull bool
+	tags      []string
+	useCache  bool
+	cacheTTL  time.Duration
+	cacheKey  string
+
+	chunkKey      string
+	chunkStart    any
+	chunkProgress func(state ChunkState)
 }
 
-func NewSelector(db *DB, table string) *Selector {
+// NewSelector 创建查询器，table 可以是表名、*Selector 子查询或 *Frame
+func NewSelector(db *DB, table any) *Selector {
 	info := &PageInfo{
 		Page:      1,
 		Count:     -1,
@@ -38,12 +57,11 @@ func NewSelector(db *DB, table string) *Selector {
 		db:        db,
 		Condition: NewCondition(),
 		PageInfo:  info,
-		table:     strings.TrimSpace(table),
 	}
-	return selector
+	return selector.From(table)
 }
 
-func DBSelector(table string) (*Selector, error) {
+func DBSelector(table any) (*Selector, error) {
 	db, err := Db()
 	if err != nil {
 		return nil, err
@@ -51,11 +69,96 @@ func DBSelector(table string) (*Selector, error) {
 	return NewSelector(db, table), nil
 }
 
+// UseSelector 创建指定名称数据库连接的查询器
+func UseSelector(name string, table any) (*Selector, error) {
+	db, err := Use(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewSelector(db, table), nil
+}
+
+// From 设置查询的表，子查询会使用其 As 设置的别名，没有设置时自动生成
+func (slt *Selector) From(table any) *Selector {
+	slt.table, slt.args = slt.tableSql(table)
+	slt.tags = tableTags(table)
+	return slt
+}
+
+// As 设置作为子查询使用时的别名
+func (slt *Selector) As(alias string) *Selector {
+	slt.alias = strings.TrimSpace(alias)
+	return slt
+}
+
+// Err 返回构建查询时产生的错误，包括条件和子查询中的错误，执行查询的方法也会返回该错误
+func (slt *Selector) Err() error {
+	if slt.err != nil {
+		return slt.err
+	}
+	if slt.Condition.err != nil {
+		return slt.Condition.err
+	}
+	if slt.having != nil && slt.having.err != nil {
+		return slt.having.err
+	}
+	if slt.Condition.expr != "" && slt.db != nil && slt.db.strict {
+		return errors.New("非法的字段名: " + slt.Condition.expr)
+	}
+	return nil
+}
+
+// setErr 记录第一个构建错误
+func (slt *Selector) setErr(err error) {
+	if slt.err == nil {
+		slt.err = err
+	}
+}
+
+// tableSql 生成表名部分，子查询返回 (sql) alias 和参数
+func (slt *Selector) tableSql(table any) (string, []any) {
+	alias := ""
+	var frame *Frame
+	switch v := table.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case *Selector:
+		slt.setErr(v.Err())
+		alias = v.alias
+		frame = v.BuildSql(false)
+	case *Frame:
+		frame = v
+	default:
+		return "", nil
+	}
+	if alias == "" {
+		slt.subs++
+		alias = "sub" + strconv.Itoa(slt.subs)
+	}
+	return "(" + frame.Sql + ") " + alias, frame.Args
+}
+
+// WithContext 设置查询使用的上下文
+func (slt *Selector) WithContext(ctx context.Context) *Selector {
+	slt.ctx = ctx
+	return slt
+}
+
+func (slt *Selector) context() context.Context {
+	if slt.ctx == nil {
+		return context.Background()
+	}
+	return slt.ctx
+}
+
 func (slt *Selector) Field(fields string, args ...any) *Selector {
 	fields = strings.TrimSpace(fields)
 	if fields == "" {
 		return slt
 	}
+	slt.setErr(selectorErr(args...))
+	slt.tags = append(slt.tags, selectorTags(args...)...)
+	fields, args = expandArgs(fields, args)
 	slt.fields = NewFrame(fields, "field", args...)
 	return slt
 }
@@ -109,67 +212,46 @@ func (slt *Selector) Having(sql string, args ...any) *Selector {
 	if slt.having == nil {
 		slt.having = NewCondition()
 	}
-	slt.having.Where(sql, args)
+	slt.having.Where(sql, args...)
 	return slt
 }
-func (slt *Selector) LeftJoin(table string, args ...any) *Selector {
-	table = strings.TrimSpace(table)
-	if table == "" {
-		return slt
-	}
-	sql := "left join " + table
-	if slt.joins == nil {
-		slt.joins = NewFrame(sql, "join", args...)
-	} else {
-		slt.joins.Add(sql, args...)
-	}
-	return slt
+func (slt *Selector) LeftJoin(table any, args ...any) *Selector {
+	return slt.join("left join", table, args)
 }
-func (slt *Selector) RightJoin(table string, args ...any) *Selector {
-	table = strings.TrimSpace(table)
-	if table == "" {
-		return slt
-	}
-	sql := "right join " + table
-	if slt.joins == nil {
-		slt.joins = NewFrame(sql, "join", args...)
-	} else {
-		slt.joins.Add(sql, args...)
-	}
-	return slt
+func (slt *Selector) RightJoin(table any, args ...any) *Selector {
+	return slt.join("right join", table, args)
 }
-func (slt *Selector) InnerJoin(table string, args ...any) *Selector {
-	table = strings.TrimSpace(table)
-	if table == "" {
-		return slt
-	}
-	sql := "inner join " + table
-	if slt.joins == nil {
-		slt.joins = NewFrame(sql, "join", args...)
-	} else {
-		slt.joins.Add(sql, args...)
-	}
-	return slt
+func (slt *Selector) InnerJoin(table any, args ...any) *Selector {
+	return slt.join("inner join", table, args)
 }
-func (slt *Selector) OuterJoin(table string, args ...any) *Selector {
-	table = strings.TrimSpace(table)
-	if table == "" {
-		return slt
+func (slt *Selector) OuterJoin(table any, args ...any) *Selector {
+	return slt.join("outer join", table, args)
+}
+func (slt *Selector) FullJoin(table any, args ...any) *Selector {
+	return slt.join("full join", table, args)
+}
+
+// join 添加连接，table 可以是表名或带有别名的 *Selector 子查询
+func (slt *Selector) join(kind string, table any, args []any) *Selector {
+	switch v := table.(type) {
+	case *Selector:
+		if v.alias == "" {
+			slt.setErr(errors.New("连接的子查询缺少别名，请先调用 As 设置别名"))
+		}
+	case *Frame:
+		slt.setErr(errors.New("连接的子查询不能使用 *Frame，请使用设置了别名的 *Selector"))
 	}
-	sql := "outer join " + table
-	if slt.joins == nil {
-		slt.joins = NewFrame(sql, "join", args...)
-	} else {
-		slt.joins.Add(sql, args...)
+	slt.setErr(selectorErr(args...))
+	if slt.err != nil {
+		return slt
 	}
-	return slt
-}
-func (slt *Selector) FullJoin(table string, args ...any) *Selector {
-	table = strings.TrimSpace(table)
-	if table == "" {
+	sql, tableArgs := slt.tableSql(table)
+	if sql == "" {
 		return slt
 	}
-	sql := "full join " + table
+	slt.tags = append(slt.tags, tableTags(table)...)
+	slt.tags = append(slt.tags, selectorTags(args...)...)
+	sql, args = expandArgs(kind+" "+sql, append(append([]any{}, tableArgs...), args...))
 	if slt.joins == nil {
 		slt.joins = NewFrame(sql, "join", args...)
 	} else {
@@ -185,40 +267,45 @@ func (slt *Selector) JoinOn(sql string, args ...any) *Selector {
 	if sql == "" {
 		return slt
 	}
-	sql = "on " + sql
+	slt.setErr(selectorErr(args...))
+	slt.tags = append(slt.tags, selectorTags(args...)...)
+	sql, args = expandArgs("on "+sql, args)
 	slt.joins.Add(sql, args...)
 	return slt
 }
-func (slt *Selector) Union(sql string, args ...any) *Selector {
-	sql = strings.TrimSpace(sql)
-	if sql == "" {
-		return slt
-	}
-	if slt.unions == nil {
-		slt.unions = make([]*Frame, 0)
-	}
-	frame := &Frame{
-		Sql:  sql,
-		Typ:  "union",
-		Args: args,
-	}
-	slt.unions = append(slt.unions, frame)
-	return slt
+func (slt *Selector) Union(sql any, args ...any) *Selector {
+	return slt.union("union", sql, args)
 }
-func (slt *Selector) UnionAll(sql string, args ...any) *Selector {
-	sql = strings.TrimSpace(sql)
-	if sql == "" {
+func (slt *Selector) UnionAll(sql any, args ...any) *Selector {
+	return slt.union("union-all", sql, args)
+}
+
+// union 添加联合查询，sql 可以是语句、*Selector 或 *Frame
+func (slt *Selector) union(typ string, sql any, args []any) *Selector {
+	var frame *Frame
+	switch v := sql.(type) {
+	case string:
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return slt
+		}
+		frame = NewFrame(v, typ, args...)
+	case *Selector:
+		slt.setErr(v.Err())
+		slt.tags = append(slt.tags, v.cacheTags()...)
+		frame = v.BuildSql(false)
+	case *Frame:
+		frame = v
+	default:
 		return slt
 	}
+	slt.setErr(selectorErr(frame.Args...))
+	slt.tags = append(slt.tags, selectorTags(frame.Args...)...)
 	if slt.unions == nil {
 		slt.unions = make([]*Frame, 0)
 	}
-	frame := &Frame{
-		Sql:  sql,
-		Typ:  "union-all",
-		Args: args,
-	}
-	slt.unions = append(slt.unions, frame)
+	tempSql, tempArgs := expandArgs(frame.Sql, frame.Args)
+	slt.unions = append(slt.unions, NewFrame(tempSql, typ, tempArgs...))
 	return slt
 }
 
@@ -244,6 +331,7 @@ func (slt *Selector) BuildSql(optimize bool) *Frame {
 	} else {
 		execSql = append(execSql, "select "+findSql+" from `"+slt.table+"`")
 	}
+	argItems = append(argItems, slt.args...)
 	//WHERE
 	if slt.joins != nil && slt.joins.Sql != "" {
 		execSql = append(execSql, slt.joins.Sql)
@@ -319,13 +407,21 @@ func (slt *Selector) BuildSql(optimize bool) *Frame {
 创建用于查询数量的语句
 */
 func (slt *Selector) BuildCount() *Frame {
+	return slt.buildAggregate("count(1)", "count(1)", "mCount", "CountTempTable")
+}
+
+/*
+*
+创建聚合查询语句，有分组、联合或 having 时 wrapExpr 作用于子查询的结果
+*/
+func (slt *Selector) buildAggregate(expr string, wrapExpr string, alias string, tempTable string) *Frame {
 	if slt.groups != nil || slt.unions != nil || slt.having != nil {
 		order := slt.orders
 		slt.orders = nil
 		limit := slt.limit
 		slt.limit = ""
 		item := slt.BuildSql(false)
-		item.Sql = "select count(1) as mCount from (" + item.Sql + ") CountTempTable"
+		item.Sql = "select " + wrapExpr + " as " + alias + " from (" + item.Sql + ") " + tempTable
 		slt.orders = order
 		slt.limit = limit
 		return item
@@ -334,10 +430,11 @@ func (slt *Selector) BuildCount() *Frame {
 	argItems := make([]any, 0)
 	reg1, _ := regexp.Compile(`\s+`)
 	if reg1.MatchString(slt.table) {
-		execSql = append(execSql, "select count(1) as mCount from "+slt.table)
+		execSql = append(execSql, "select "+expr+" as "+alias+" from "+slt.table)
 	} else {
-		execSql = append(execSql, "select count(1) as mCount from `"+slt.table+"`")
+		execSql = append(execSql, "select "+expr+" as "+alias+" from `"+slt.table+"`")
 	}
+	argItems = append(argItems, slt.args...)
 	//JOIN
 	if slt.joins != nil && slt.joins.Sql != "" {
 		execSql = append(execSql, slt.joins.Sql)
@@ -382,8 +479,16 @@ func (slt *Selector) SetPage(page int, pageSize int) *Selector {
 获取分页数据
 */
 func (slt *Selector) GetPageInfo() (*PageInfo, error) {
+	return slt.GetPageInfoContext(slt.context())
+}
+
+/*
+*
+带有上下文获取分页数据
+*/
+func (slt *Selector) GetPageInfoContext(ctx context.Context) (*PageInfo, error) {
 	if slt.Count == -1 {
-		_, err := slt.GetCount()
+		_, err := slt.GetCountContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -406,6 +511,35 @@ func (slt *Selector) GetPageInfo() (*PageInfo, error) {
 获取分页列表
 */
 func (slt *Selector) PageList() ([]H, error) {
+	return slt.PageListContext(slt.context())
+}
+
+/*
+*
+带有上下文获取分页列表
+*/
+func (slt *Selector) PageListContext(ctx context.Context) ([]H, error) {
+	item := slt.buildPageSql()
+	return slt.query(ctx, item)
+}
+
+/*
+*
+获取分页列表并映射到结构体切片
+*/
+func (slt *Selector) PageListInto(dest any) error {
+	if err := slt.Err(); err != nil {
+		return err
+	}
+	item := slt.buildPageSql()
+	rows, err := slt.db.QueryRowsContext(slt.context(), item.Sql, item.Args...)
+	if err != nil {
+		return err
+	}
+	return scanAll(rows, dest)
+}
+
+func (slt *Selector) buildPageSql() *Frame {
 	if slt.Page < 1 {
 		slt.Page = 1
 	}
@@ -417,7 +551,7 @@ func (slt *Selector) PageList() ([]H, error) {
 	slt.limit = "limit " + strconv.Itoa(offset) + "," + strconv.Itoa(slt.PageSize)
 	item := slt.BuildSql(true)
 	slt.limit = limit
-	return slt.db.Query(item.Sql, item.Args...)
+	return item
 }
 
 /*
@@ -425,9 +559,17 @@ func (slt *Selector) PageList() ([]H, error) {
 获取数量
 */
 func (slt *Selector) GetCount() (int, error) {
+	return slt.GetCountContext(slt.context())
+}
+
+/*
+*
+带有上下文获取数量
+*/
+func (slt *Selector) GetCountContext(ctx context.Context) (int, error) {
 	count := 0
 	item := slt.BuildCount()
-	row, err := slt.db.QueryRow(item.Sql, item.Args...)
+	row, err := slt.queryRow(ctx, item)
 	if err != nil {
 		return 0, err
 	}
@@ -441,6 +583,54 @@ func (slt *Selector) GetCount() (int, error) {
 获取查询结果
 */
 func (slt *Selector) GetList() ([]H, error) {
+	return slt.GetListContext(slt.context())
+}
+
+/*
+*
+带有上下文获取查询结果
+*/
+func (slt *Selector) GetListContext(ctx context.Context) ([]H, error) {
 	item := slt.BuildSql(true)
-	return slt.db.Query(item.Sql, item.Args...)
+	return slt.query(ctx, item)
+}
+
+/*
+*
+获取查询结果并映射到结构体切片
+*/
+func (slt *Selector) GetListInto(dest any) error {
+	if err := slt.Err(); err != nil {
+		return err
+	}
+	item := slt.BuildSql(true)
+	rows, err := slt.db.QueryRowsContext(slt.context(), item.Sql, item.Args...)
+	if err != nil {
+		return err
+	}
+	return scanAll(rows, dest)
+}
+
+/*
+*
+逐行遍历查询结果，fn 返回 ErrStop 时提前结束
+*/
+func (slt *Selector) Each(fn func(H) error) error {
+	return slt.EachContext(slt.context(), fn)
+}
+
+/*
+*
+带有上下文逐行遍历查询结果
+*/
+func (slt *Selector) EachContext(ctx context.Context, fn func(H) error) error {
+	if err := slt.Err(); err != nil {
+		return err
+	}
+	item := slt.BuildSql(true)
+	iter, err := slt.db.QueryIterContext(ctx, item.Sql, item.Args...)
+	if err != nil {
+		return err
+	}
+	return iter.Each(fn)
 }
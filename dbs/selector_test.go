@@ -0,0 +1,238 @@
+package dbs
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestKeysetWhere(t *testing.T) {
+	columns, err := parseKeysetOrders([]string{"a.created_at desc", "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := keysetWhere(columns, []any{"2023-01-01", 5}, false)
+	want := "(`a`.`created_at` < ? or (`a`.`created_at` = ? and `id` > ?))"
+	if frame.Sql != want {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if !reflect.DeepEqual(frame.Args, []any{"2023-01-01", "2023-01-01", 5}) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+	frame = keysetWhere(columns, []any{"2023-01-01", 5}, true)
+	if frame.Sql != "(`a`.`created_at` > ? or (`a`.`created_at` = ? and `id` < ?))" {
+		t.Fatalf("prev sql = %s", frame.Sql)
+	}
+	for _, order := range []string{"id; drop table user", "`id`", "a.`id`"} {
+		if _, err = parseKeysetOrders([]string{order}); err == nil {
+			t.Fatalf("invalid column should be rejected: %s", order)
+		}
+	}
+}
+
+func TestKeysetCursor(t *testing.T) {
+	columns, _ := parseKeysetOrders([]string{"score desc", "name", "id"})
+	cursor, err := encodeCursor(columns, H{"score": 1.5, "name": "goyee", "id": 12}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := decodeCursor(columns, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !item.Prev || !reflect.DeepEqual(item.Values, []any{1.5, "goyee", int64(12)}) {
+		t.Fatalf("cursor = %+v", item)
+	}
+	if _, err = decodeCursor(columns, "bad"); err == nil {
+		t.Fatal("invalid cursor should be rejected")
+	}
+}
+
+func TestKeysetList(t *testing.T) {
+	var query string
+	var args []any
+	srv := &fakeServer{query: func(q string, a []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		query, args = q, fakeArgs(a)
+		return []string{"id", "name"}, [][]driver.Value{{int64(8), "a"}, {int64(7), "b"}, {int64(6), "c"}}, nil
+	}}
+	slt := NewSelector(newFakeDB(srv), "@pf_user")
+	slt.Where("status=?", 1).OrWhere("vip=?", 1)
+	page, err := slt.KeysetList("", 2, "id desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.List) != 2 || page.Next == "" || page.Prev != "" {
+		t.Fatalf("page = %+v", page)
+	}
+	if _, err = slt.KeysetList(page.Next, 2, "id desc"); err != nil {
+		t.Fatal(err)
+	}
+	want := "select * from `user` where (status=? or vip=?) and (`id` < ?) order by `id` desc limit 0,3"
+	if query != want || !reflect.DeepEqual(args, []any{int64(1), int64(1), int64(7)}) {
+		t.Fatalf("sql = %s %v", query, args)
+	}
+	if _, err = slt.KeysetList("", 2, "created_at desc"); err == nil {
+		t.Fatal("missing order column should be rejected")
+	}
+}
+
+func TestSubSelector(t *testing.T) {
+	orders := NewSelector(nil, "@pf_order").Field("user_id,sum(amount) as total").Group("user_id").As("o")
+	orders.Where("state=?", 1)
+	vip := NewSelector(nil, "@pf_vip").Field("user_id")
+	vip.Where("level>?", 2)
+	orders.Having("sum(amount)>?", 5)
+	slt := NewSelector(nil, "@pf_user u").Field("u.*,o.total")
+	slt.LeftJoin(orders).JoinOn("o.user_id=u.id")
+	slt.Where("u.type=?", 3)
+	slt.In("u.id", vip)
+	slt.Union(NewSelector(nil, "@pf_guest").Field("*,0 as total"))
+	frame := slt.BuildSql(false)
+	want := "( select u.*,o.total from @pf_user u left join (select user_id,sum(amount) as total from `@pf_order` where state=? group by user_id having sum(amount)>?) o on o.user_id=u.id" +
+		" where u.type=? and `u`.`id` in (select user_id from `@pf_vip` where level>?) ) union ( select *,0 as total from `@pf_guest` )"
+	if frame.Sql != want {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if !reflect.DeepEqual(frame.Args, []any{1, 5, 3, 2}) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+	from := NewSelector(nil, NewSelector(nil, "@pf_log").Field("uid").Group("uid"))
+	from.Where("uid>?", 10)
+	frame = from.BuildCount()
+	if frame.Sql != "select count(1) as mCount from (select uid from `@pf_log` group by uid) sub1 where uid>?" {
+		t.Fatalf("count sql = %s", frame.Sql)
+	}
+}
+
+func TestBuildAggregate(t *testing.T) {
+	slt := NewSelector(nil, "@pf_order o").LeftJoin("@pf_user u").JoinOn("u.id=o.user_id")
+	slt.Where("o.state=?", 1)
+	frame := slt.buildAggregate("sum(o.amount)", "sum(amount)", "mValue", "AggTempTable")
+	want := "select sum(o.amount) as mValue from @pf_order o left join @pf_user u on u.id=o.user_id where o.state=?"
+	if frame.Sql != want {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	slt.Field("o.user_id").Group("o.user_id").Having("count(1)>?", 2).Order("o.user_id desc")
+	frame, err := slt.buildAggregateSql("sum", "o.amount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "select sum(mValue) as mValue from (select o.user_id,sum(o.amount) as mValue from @pf_order o left join @pf_user u on u.id=o.user_id where o.state=? group by o.user_id having count(1)>?) AggTempTable"
+	if frame.Sql != want || !reflect.DeepEqual(frame.Args, []any{1, 2}) {
+		t.Fatalf("group sql = %s %v", frame.Sql, frame.Args)
+	}
+	frame, _ = slt.buildAggregateSql("avg", "o.amount")
+	want = "select sum(mValue)/sum(mCount) as mValue from (select o.user_id,sum(o.amount) as mValue,count(o.amount) as mCount from @pf_order o left join @pf_user u on u.id=o.user_id where o.state=? group by o.user_id having count(1)>?) AggTempTable"
+	if frame.Sql != want {
+		t.Fatalf("avg sql = %s", frame.Sql)
+	}
+	if frame = slt.BuildSql(false); frame.Sql != "select o.user_id from @pf_order o left join @pf_user u on u.id=o.user_id where o.state=? group by o.user_id having count(1)>? order by o.user_id desc" {
+		t.Fatalf("fields should be restored: %s", frame.Sql)
+	}
+}
+
+func TestSumDecimal(t *testing.T) {
+	srv := &fakeServer{query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		return []string{"mValue"}, [][]driver.Value{{"12345678901234.56"}}, nil
+	}}
+	sum, err := NewSelector(newFakeDB(srv), "@pf_order").SumDecimal("amount")
+	if err != nil || sum != "12345678901234.56" {
+		t.Fatalf("sum = %s, err = %v", sum, err)
+	}
+}
+
+func TestSelectorModify(t *testing.T) {
+	slt := NewSelector(nil, "@pf_user")
+	if _, err := slt.BuildDelete(); err == nil {
+		t.Fatal("delete without where should be rejected")
+	}
+	frame, err := slt.AllowFull().BuildDelete()
+	if err != nil || frame.Sql != "delete from `@pf_user`" {
+		t.Fatalf("sql = %v %v", frame, err)
+	}
+	slt = NewSelector(nil, "@pf_user").Order("id desc").Limit(0, 10)
+	slt.Where("status=?", 0)
+	frame, err = slt.BuildUpdate(H{"status": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Sql != "update `@pf_user` set `status`=? where status=? order by id desc limit 10" {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if !reflect.DeepEqual(frame.Args, []any{1, 0}) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+	if _, err = slt.Limit(5, 10).BuildDelete(); err == nil {
+		t.Fatal("limit with offset should be rejected")
+	}
+	slt = NewSelector(nil, "@pf_user u").LeftJoin("@pf_role r").JoinOn("r.id=u.role_id and r.type=?", 2)
+	slt.Where("r.name=?", "guest")
+	frame, err = slt.BuildDelete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Sql != "delete u from @pf_user u left join @pf_role r on r.id=u.role_id and r.type=? where r.name=?" {
+		t.Fatalf("sql = %s", frame.Sql)
+	}
+	if !reflect.DeepEqual(frame.Args, []any{2, "guest"}) {
+		t.Fatalf("args = %v", frame.Args)
+	}
+	if _, err = slt.Order("u.id").BuildUpdate(H{"a": 1}); err == nil {
+		t.Fatal("join with order should be rejected")
+	}
+}
+
+func TestJoinAlias(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	sub := NewSelector(db, "@pf_order").Field("user_id")
+	slt := NewSelector(db, "@pf_user u")
+	slt.LeftJoin(sub).JoinOn("sub1.user_id=u.id")
+	if slt.Err() == nil {
+		t.Fatal("join without alias should be rejected")
+	}
+	if _, err := slt.GetList(); err != slt.Err() {
+		t.Fatalf("err = %v", err)
+	}
+	if err := NewSelector(db, "@pf_user u").InnerJoin(NewFrame("select 1", "")).Err(); err == nil {
+		t.Fatal("join with a frame should be rejected")
+	}
+	if len(srv.statements()) != 0 {
+		t.Fatalf("statements = %q", srv.statements())
+	}
+	if err := NewSelector(db, "@pf_user u").LeftJoin(sub.As("o")).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubSelectorErr(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	bad := func() *Selector {
+		return NewSelector(db, "@pf_order o").LeftJoin(NewSelector(db, "@pf_item"))
+	}
+	cases := map[string]func(slt *Selector){
+		"from":    func(slt *Selector) { slt.From(bad()) },
+		"where":   func(slt *Selector) { slt.Where("id in ?", bad()) },
+		"in":      func(slt *Selector) { slt.In("id", bad()) },
+		"exists":  func(slt *Selector) { slt.Condition.Exists(bad()) },
+		"cond":    func(slt *Selector) { slt.WhereC(NewCondition().In("id", bad())) },
+		"union":   func(slt *Selector) { slt.Union(bad()) },
+		"field":   func(slt *Selector) { slt.Field("(?) as total", bad()) },
+		"having":  func(slt *Selector) { slt.Group("type").Having("count(*) > ?", bad()) },
+		"join on": func(slt *Selector) { slt.InnerJoin("@pf_order o").JoinOn("o.id in ?", bad()) },
+	}
+	for name, fn := range cases {
+		slt := NewSelector(db, "@pf_user u")
+		fn(slt)
+		if slt.Err() == nil {
+			t.Fatalf("%s: sub selector error should be kept", name)
+		}
+		if _, err := slt.GetList(); err != slt.Err() {
+			t.Fatalf("%s: err = %v", name, err)
+		}
+	}
+	if len(srv.statements()) != 0 {
+		t.Fatalf("statements = %q", srv.statements())
+	}
+}
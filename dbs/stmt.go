@@ -0,0 +1,211 @@
+package dbs
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtKey 缓存键，同一语句在主库和各个从库上分别预处理
+type stmtKey struct {
+	conn  *sql.DB
+	query string
+}
+
+type stmtEntry struct {
+	key     stmtKey
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// StmtCacheStats 预处理语句缓存的统计
+type StmtCacheStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// stmtCache 预处理语句的 LRU 缓存，被淘汰的语句在不再使用后关闭
+type stmtCache struct {
+	mutex     sync.Mutex
+	size      int
+	list      *list.List
+	items     map[stmtKey]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		list:  list.New(),
+		items: make(map[stmtKey]*list.Element),
+	}
+}
+
+// get 取得缓存的语句并增加引用
+func (c *stmtCache) get(key stmtKey) *stmtEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.list.MoveToFront(el)
+		c.hits++
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry
+	}
+	c.misses++
+	return nil
+}
+
+// add 加入新预处理的语句并增加引用，返回需要关闭的语句
+func (c *stmtCache) add(key stmtKey, stmt *sql.Stmt) (*stmtEntry, []*sql.Stmt) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var closed []*sql.Stmt
+	if el, ok := c.items[key]; ok {
+		// 并发预处理了同一语句，保留已缓存的
+		closed = append(closed, stmt)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry, closed
+	}
+	entry := &stmtEntry{key: key, stmt: stmt, refs: 1}
+	c.items[key] = c.list.PushFront(entry)
+	for c.list.Len() > c.size {
+		el := c.list.Back()
+		old := el.Value.(*stmtEntry)
+		c.list.Remove(el)
+		delete(c.items, old.key)
+		c.evictions++
+		old.evicted = true
+		if old.refs == 0 {
+			closed = append(closed, old.stmt)
+		}
+	}
+	return entry, closed
+}
+
+// release 减少引用，已淘汰且不再使用的语句会被关闭
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mutex.Lock()
+	entry.refs--
+	closed := entry.evicted && entry.refs == 0
+	c.mutex.Unlock()
+	if closed {
+		entry.stmt.Close()
+	}
+}
+
+// close 清空缓存并关闭未在使用的语句
+func (c *stmtCache) close() {
+	c.mutex.Lock()
+	var closed []*sql.Stmt
+	for el := c.list.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*stmtEntry)
+		entry.evicted = true
+		if entry.refs == 0 {
+			closed = append(closed, entry.stmt)
+		}
+	}
+	c.list.Init()
+	c.items = make(map[stmtKey]*list.Element)
+	c.mutex.Unlock()
+	for _, stmt := range closed {
+		stmt.Close()
+	}
+}
+
+func (c *stmtCache) stats() StmtCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return StmtCacheStats{Size: c.list.Len(), Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+}
+
+/*
+*
+设置预处理语句缓存的最大数量，0 表示关闭缓存
+开启后带参数的 Query、QueryRow 和 Exec 会复用预处理语句
+*/
+func (db *DB) SetStmtCache(size int) *DB {
+	if db.stmts != nil {
+		db.stmts.close()
+		db.stmts = nil
+	}
+	if size > 0 {
+		db.stmts = newStmtCache(size)
+	}
+	return db
+}
+
+// StmtCacheStats 返回预处理语句缓存的统计，未开启时返回零值
+func (db *DB) StmtCacheStats() StmtCacheStats {
+	if db.stmts == nil {
+		return StmtCacheStats{}
+	}
+	return db.stmts.stats()
+}
+
+// stmt 从缓存取得或预处理语句，不使用缓存或预处理失败时返回 nil，使用完毕后需调用 release
+func (db *DB) stmt(ctx context.Context, conn *sql.DB, query string, args []any) (*sql.Stmt, func()) {
+	cache := db.stmts
+	if cache == nil || len(args) == 0 {
+		return nil, nil
+	}
+	if entry := cache.get(stmtKey{conn: conn, query: query}); entry != nil {
+		return entry.stmt, func() {
+			cache.release(entry)
+		}
+	}
+	return db.prepareStmt(ctx, conn, query)
+}
+
+// prepareStmt 预处理语句并加入缓存，失败时返回 nil
+func (db *DB) prepareStmt(ctx context.Context, conn *sql.DB, query string) (*sql.Stmt, func()) {
+	cache := db.stmts
+	stmt, err := conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil
+	}
+	entry, closed := cache.add(stmtKey{conn: conn, query: query}, stmt)
+	for _, item := range closed {
+		item.Close()
+	}
+	return entry.stmt, func() {
+		cache.release(entry)
+	}
+}
+
+// stmt 事务中取得缓存的语句，未缓存时先在连接池上预处理并加入缓存，再通过 tx.Stmt 绑定到事务连接
+// 连接池已满时不再预处理，避免等待事务自身占用的连接
+func (tx *Tx) stmt(ctx context.Context, query string, args []any) (*sql.Stmt, func()) {
+	cache := tx.db.stmts
+	if cache == nil || len(args) == 0 {
+		return nil, nil
+	}
+	conn := tx.db.DB
+	var stmt *sql.Stmt
+	var release func()
+	if entry := cache.get(stmtKey{conn: conn, query: query}); entry != nil {
+		stmt = entry.stmt
+		release = func() {
+			cache.release(entry)
+		}
+	} else {
+		if s := conn.Stats(); s.MaxOpenConnections > 0 && s.InUse >= s.MaxOpenConnections {
+			return nil, nil
+		}
+		if stmt, release = tx.db.prepareStmt(ctx, conn, query); stmt == nil {
+			return nil, nil
+		}
+	}
+	txStmt := tx.StmtContext(ctx, stmt)
+	return txStmt, func() {
+		txStmt.Close()
+		release()
+	}
+}
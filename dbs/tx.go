@@ -0,0 +1,74 @@
+package dbs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Commit 提交事务，成功后执行 AfterCommit 注册的函数
+func (tx *Tx) Commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		tx.hooks = nil
+		return err
+	}
+	hooks := tx.hooks
+	tx.hooks = nil
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+// Rollback 回滚事务，并丢弃 AfterCommit 注册的函数
+func (tx *Tx) Rollback() error {
+	tx.hooks = nil
+	return tx.Tx.Rollback()
+}
+
+// AfterCommit 注册事务提交成功后执行的函数，事务或所在的保存点回滚时不会执行
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.hooks = append(tx.hooks, fn)
+}
+
+// Transaction 嵌套事务，使用保存点实现，fn 返回错误或发生异常时回滚到保存点
+func (tx *Tx) Transaction(fn func(*Tx) error) error {
+	return tx.TransactionContext(context.Background(), fn)
+}
+
+// TransactionContext 带有上下文的嵌套事务，fn 发生异常时回滚到保存点后继续抛出
+func (tx *Tx) TransactionContext(ctx context.Context, fn func(*Tx) error) error {
+	tx.savepoint++
+	name := "sp_" + strconv.Itoa(tx.savepoint)
+	if _, err := tx.Tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	hooks := len(tx.hooks)
+	rollback := func() error {
+		tx.hooks = tx.hooks[:hooks]
+		_, err := tx.Tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+	err := tx.call(fn, func() {
+		rollback()
+	})
+	if err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			return fmt.Errorf("%w，回滚保存点失败: %v", err, rbErr)
+		}
+		return err
+	}
+	_, err = tx.Tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// call 执行事务函数，发生异常时先执行 rollback 再继续抛出，保留原始的调用栈
+func (tx *Tx) call(fn func(*Tx) error, rollback func()) error {
+	defer func() {
+		if r := recover(); r != nil {
+			rollback()
+			panic(r)
+		}
+	}()
+	return fn(tx)
+}
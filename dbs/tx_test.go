@@ -0,0 +1,97 @@
+package dbs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNestedTransaction(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	calls := make([]string, 0)
+	err := db.Transaction(func(tx *Tx) error {
+		tx.AfterCommit(func() { calls = append(calls, "outer") })
+		err := tx.Transaction(func(tx *Tx) error {
+			tx.AfterCommit(func() { calls = append(calls, "discarded") })
+			return errors.New("fail")
+		})
+		if err == nil || err.Error() != "fail" {
+			t.Fatalf("nested error = %v", err)
+		}
+		return tx.Transaction(func(tx *Tx) error {
+			tx.AfterCommit(func() { calls = append(calls, "inner") })
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_2", "COMMIT"}
+	if got := srv.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q", got)
+	}
+	if !reflect.DeepEqual(calls, []string{"outer", "inner"}) {
+		t.Fatalf("hooks = %q", calls)
+	}
+}
+
+func TestTransactionRollbackHooks(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	called := false
+	err := db.Transaction(func(tx *Tx) error {
+		tx.AfterCommit(func() { called = true })
+		return errors.New("fail")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if called {
+		t.Fatal("hook should not run after rollback")
+	}
+	if got := srv.statements(); !reflect.DeepEqual(got, []string{"BEGIN", "ROLLBACK"}) {
+		t.Fatalf("statements = %q", got)
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recover = %v", r)
+			}
+		}()
+		db.Transaction(func(tx *Tx) error {
+			return tx.Transaction(func(tx *Tx) error {
+				panic("boom")
+			})
+		})
+		t.Fatal("panic should be rethrown")
+	}()
+	want := []string{"BEGIN", "SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "ROLLBACK"}
+	if got := srv.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q", got)
+	}
+}
+
+func TestAfterCommitPanic(t *testing.T) {
+	srv := &fakeServer{}
+	db := newFakeDB(srv)
+	func() {
+		defer func() {
+			if r := recover(); r != "hook" {
+				t.Fatalf("recover = %v", r)
+			}
+		}()
+		db.Transaction(func(tx *Tx) error {
+			tx.AfterCommit(func() { panic("hook") })
+			return nil
+		})
+	}()
+	if got := srv.statements(); !reflect.DeepEqual(got, []string{"BEGIN", "COMMIT"}) {
+		t.Fatalf("statements = %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package dbs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Values 引用插入语句中字段的值，用于 Upsert 的更新部分
+func Values(column string) *Frame {
+	return Raw("VALUES(" + QuoteIdent(column) + ")")
+}
+
+// buildUpsert 生成 insert ... on duplicate key update 语句
+// update 可以是 nil(更新全部插入字段)、H、[]string 字段列表、逗号分隔的字段字符串、*Frame 或由它们组成的 []any
+func buildUpsert(table string, data H, update any) (*Frame, error) {
+	frame, err := buildInsert("insert", table, data)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	var values []any
+	addItem := func(item any) error {
+		switch v := item.(type) {
+		case string:
+			for _, column := range strings.Split(v, ",") {
+				column = strings.TrimSpace(column)
+				if column != "" {
+					names = append(names, QuoteIdent(column)+"="+Values(column).Sql)
+				}
+			}
+		case *Frame:
+			names = append(names, v.Format())
+		default:
+			return errors.New("更新，不支持的更新字段类型")
+		}
+		return nil
+	}
+	switch v := update.(type) {
+	case nil:
+		for key := range data {
+			names = append(names, QuoteIdent(key)+"="+Values(key).Sql)
+		}
+	case H:
+		for key, value := range v {
+			switch value.(type) {
+			case *Frame:
+				names = append(names, QuoteIdent(key)+"="+value.(*Frame).Format())
+			default:
+				names = append(names, QuoteIdent(key)+"=?")
+				values = append(values, value)
+			}
+		}
+	case []string:
+		for _, column := range v {
+			if err = addItem(column); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err = addItem(item); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if err = addItem(v); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("更新，没有相应的数据")
+	}
+	frame.Sql += " on duplicate key update " + strings.Join(names, ",")
+	frame.Args = append(frame.Args, values...)
+	return frame, nil
+}
+
+// writePreparer DB 和 Tx 共有的写入校验
+type writePreparer interface {
+	prepareWrite(ctx context.Context, table string, data H) (H, error)
+}
+
+// prepareUpsert 校验并处理插入和更新的字段
+func prepareUpsert(ctx context.Context, p writePreparer, table string, data H, update any) (H, any, error) {
+	data, err := p.prepareWrite(ctx, table, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v, ok := update.(H); ok {
+		if update, err = p.prepareWrite(ctx, table, v); err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, update, nil
+}
+
+// Upsert 插入数据集，主键或唯一键冲突时按 update 更新
+func (db *DB) Upsert(table string, data H, update any) (sql.Result, error) {
+	return db.UpsertContext(context.Background(), table, data, update)
+}
+
+// UpsertContext 带有上下文插入数据集，主键或唯一键冲突时按 update 更新
+func (db *DB) UpsertContext(ctx context.Context, table string, data H, update any) (sql.Result, error) {
+	data, update, err := prepareUpsert(ctx, db, table, data, update)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := buildUpsert(table, data, update)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	db.invalidate(table)
+	return res, nil
+}
+
+// Upsert 插入数据集，主键或唯一键冲突时按 update 更新
+func (tx *Tx) Upsert(table string, data H, update any) (sql.Result, error) {
+	return tx.UpsertContext(context.Background(), table, data, update)
+}
+
+// UpsertContext 带有上下文插入数据集，主键或唯一键冲突时按 update 更新
+func (tx *Tx) UpsertContext(ctx context.Context, table string, data H, update any) (sql.Result, error) {
+	data, update, err := prepareUpsert(ctx, tx, table, data, update)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := buildUpsert(table, data, update)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, frame.Sql, frame.Args...)
+	if err != nil {
+		return nil, err
+	}
+	tx.invalidate(table)
+	return res, nil
+}
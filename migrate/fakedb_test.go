@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeServer 测试用的数据库，记录执行的语句并模拟版本表
+type fakeServer struct {
+	mutex   sync.Mutex
+	log     []string
+	created bool
+	applied map[int64]string
+	fail    string // 执行包含该内容的语句时返回错误
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{applied: make(map[int64]string)}
+}
+
+func (srv *fakeServer) db() *sql.DB {
+	return sql.OpenDB(&fakeConnector{srv: srv})
+}
+
+func (srv *fakeServer) statements() []string {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return append([]string(nil), srv.log...)
+}
+
+func (srv *fakeServer) exec(query string, args []driver.NamedValue) error {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.log = append(srv.log, query)
+	if srv.fail != "" && strings.Contains(query, srv.fail) {
+		return errors.New("exec failed")
+	}
+	switch {
+	case strings.HasPrefix(query, "create table if not exists"):
+		srv.created = true
+	case strings.HasPrefix(query, "insert into `t_schema_migrations`"):
+		srv.applied[args[0].Value.(int64)] = args[1].Value.(string)
+	case strings.HasPrefix(query, "delete from `t_schema_migrations`"):
+		delete(srv.applied, args[0].Value.(int64))
+	}
+	return nil
+}
+
+func (srv *fakeServer) query(query string) ([]string, [][]driver.Value) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.log = append(srv.log, query)
+	switch {
+	case strings.HasPrefix(query, "select GET_LOCK"):
+		return []string{"locked"}, [][]driver.Value{{int64(1)}}
+	case strings.Contains(query, "information_schema.tables"):
+		if srv.created {
+			return []string{"1"}, [][]driver.Value{{int64(1)}}
+		}
+		return []string{"1"}, nil
+	case strings.Contains(query, "from `t_schema_migrations`"):
+		versions := make([]int64, 0, len(srv.applied))
+		for version := range srv.applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] < versions[j]
+		})
+		rows := make([][]driver.Value, 0, len(versions))
+		for _, version := range versions {
+			rows = append(rows, []driver.Value{version, srv.applied[version], time.Now()})
+		}
+		return []string{"version", "name", "applied_at"}, rows
+	}
+	return nil, nil
+}
+
+type fakeConnector struct {
+	srv *fakeServer
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{srv: c.srv}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeConn struct {
+	srv *fakeServer
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.srv.exec("BEGIN", nil)
+	return &fakeTx{srv: c.srv}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.srv.exec(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	columns, rows := c.srv.query(query)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeTx struct {
+	srv *fakeServer
+}
+
+func (tx *fakeTx) Commit() error {
+	return tx.srv.exec("COMMIT", nil)
+}
+
+func (tx *fakeTx) Rollback() error {
+	return tx.srv.exec("ROLLBACK", nil)
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,499 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/wj008/goyee/dbs"
+	"github.com/wj008/goyee/logger"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Executor 迁移中执行语句的对象，语句中的 @pf_ 会被替换为表前缀
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Func 迁移函数
+type Func func(ctx context.Context, ex Executor) error
+
+// Migration 单个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Func
+	Down    Func
+	NoTx    bool // 不在事务中执行，包含 DDL 的 SQL 文件会自动设置，中途失败时已执行的语句不会回滚
+}
+
+// Status 迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db          *dbs.DB
+	table       string
+	lockName    string
+	lockTimeout int
+	migrations  map[int64]*Migration
+}
+
+// New 创建迁移执行器，默认使用 @pf_schema_migrations 记录已执行的版本
+func New(db *dbs.DB) *Migrator {
+	return &Migrator{
+		db:          db,
+		table:       "@pf_schema_migrations",
+		lockName:    "goyee_migrate",
+		lockTimeout: 30,
+		migrations:  make(map[int64]*Migration),
+	}
+}
+
+// Table 设置记录版本的表名
+func (m *Migrator) Table(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+// Lock 设置互斥锁的名称和等待秒数
+func (m *Migrator) Lock(name string, timeout int) *Migrator {
+	m.lockName = name
+	m.lockTimeout = timeout
+	return m
+}
+
+// Add 添加迁移，版本号不能重复
+func (m *Migrator) Add(migration *Migration) error {
+	if migration.Version <= 0 {
+		return errors.New("迁移版本号必须大于0")
+	}
+	if migration.Up == nil {
+		return fmt.Errorf("迁移 %d 缺少 Up", migration.Version)
+	}
+	if _, ok := m.migrations[migration.Version]; ok {
+		return fmt.Errorf("迁移版本 %d 重复", migration.Version)
+	}
+	m.migrations[migration.Version] = migration
+	return nil
+}
+
+// fileReg 迁移文件名，如 0001_create_user.up.sql
+var fileReg = regexp.MustCompile(`^(\d+)_([\w-]+)\.(up|down)\.sql$`)
+
+// parseName 解析迁移文件名
+func parseName(name string) (version int64, title string, up bool, ok bool) {
+	match := fileReg.FindStringSubmatch(name)
+	if match == nil {
+		return 0, "", false, false
+	}
+	version, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", false, false
+	}
+	return version, match[2], match[3] == "up", true
+}
+
+// LoadDir 加载目录中的 SQL 迁移文件
+func (m *Migrator) LoadDir(dir string) error {
+	return m.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS 加载文件系统中的 SQL 迁移文件，可配合 embed.FS 使用
+func (m *Migrator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	files := make(map[int64]*Migration)
+	downs := make(map[int64][]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		version, title, up, ok := parseName(entry.Name())
+		if !ok {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		statements := splitStatements(string(data))
+		if !up {
+			downs[version] = statements
+			continue
+		}
+		if _, ok := files[version]; ok {
+			return fmt.Errorf("迁移版本 %d 重复", version)
+		}
+		files[version] = &Migration{
+			Version: version,
+			Name:    title,
+			Up:      sqlFunc(statements),
+			NoTx:    hasDDL(statements),
+		}
+	}
+	for version, statements := range downs {
+		migration, ok := files[version]
+		if !ok {
+			return fmt.Errorf("迁移版本 %d 缺少 up 文件", version)
+		}
+		migration.Down = sqlFunc(statements)
+		migration.NoTx = migration.NoTx || hasDDL(statements)
+	}
+	for _, migration := range files {
+		if err = m.Add(migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlFunc 依次执行语句，失败时返回语句的序号和内容
+func sqlFunc(statements []string) Func {
+	return func(ctx context.Context, ex Executor) error {
+		for i, query := range statements {
+			if _, err := ex.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("第 %d 条语句执行失败 [%s]: %w", i+1, query, err)
+			}
+		}
+		return nil
+	}
+}
+
+// ddlReg 会导致 MySQL 隐式提交的语句
+var ddlReg = regexp.MustCompile(`(?i)^(create|alter|drop|rename|truncate)\s`)
+
+// hasDDL 是否包含 DDL 语句，MySQL 中 DDL 会隐式提交事务
+func hasDDL(statements []string) bool {
+	for _, query := range statements {
+		if ddlReg.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitStatements 按分号拆分语句，忽略引号和注释中的分号，并去掉注释
+func splitStatements(text string) []string {
+	statements := make([]string, 0)
+	var buf strings.Builder
+	flush := func() {
+		query := strings.TrimSpace(buf.String())
+		if query != "" {
+			statements = append(statements, query)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(text) {
+				if text[j] == '\\' && c != '`' {
+					j += 2
+					continue
+				}
+				if text[j] == c {
+					if j+1 < len(text) && text[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j >= len(text) {
+				j = len(text) - 1
+			}
+			buf.WriteString(text[i : j+1])
+			i = j
+		case c == '#' || (c == '-' && strings.HasPrefix(text[i:], "-- ")) || (c == '-' && strings.HasPrefix(text[i:], "--\n")):
+			j := strings.IndexByte(text[i:], '\n')
+			if j < 0 {
+				i = len(text)
+			} else {
+				i += j
+				buf.WriteByte('\n')
+			}
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			j := strings.Index(text[i+2:], "*/")
+			if j < 0 {
+				i = len(text)
+			} else {
+				i += j + 3
+				buf.WriteByte(' ')
+			}
+		case c == ';':
+			flush()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// prefixed 替换表前缀后执行
+type prefixed struct {
+	ex     Executor
+	prefix string
+}
+
+func (p *prefixed) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return p.ex.ExecContext(ctx, strings.Replace(query, "@pf_", p.prefix, -1), args...)
+}
+
+func (p *prefixed) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.ex.QueryContext(ctx, strings.Replace(query, "@pf_", p.prefix, -1), args...)
+}
+
+// withLock 在独占的连接上获取 GET_LOCK 后执行，防止多个进程同时迁移
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer m.db.RefreshSchema()
+	var locked sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "select GET_LOCK(?, ?)", m.lockName, m.lockTimeout).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return errors.New("获取迁移锁失败，可能有其他进程正在迁移")
+	}
+	defer conn.ExecContext(context.Background(), "select RELEASE_LOCK(?)", m.lockName)
+	ex := &prefixed{ex: conn, prefix: m.db.Prefix()}
+	_, err = ex.ExecContext(ctx, "create table if not exists "+dbs.QuoteIdent(m.table)+" (`version` bigint not null primary key, `name` varchar(255) not null, `applied_at` datetime not null)")
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// applied 读取已执行的版本
+func (m *Migrator) applied(ctx context.Context, ex Executor) (map[int64]Status, error) {
+	rows, err := ex.QueryContext(ctx, "select `version`, `name`, `applied_at` from "+dbs.QuoteIdent(m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make(map[int64]Status)
+	for rows.Next() {
+		item := Status{Applied: true}
+		var appliedAt sql.NullTime
+		if err = rows.Scan(&item.Version, &item.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		item.AppliedAt = appliedAt.Time
+		list[item.Version] = item
+	}
+	return list, rows.Err()
+}
+
+// run 执行一个迁移并更新版本记录，NoTx 以外的迁移在事务中执行
+// NoTx 的迁移不是原子的，MySQL 的 DDL 会隐式提交，失败时之前的语句已经生效且不会记录版本
+// 需要根据错误中的语句序号手动处理后再重试，因此包含 DDL 的文件建议只写一条语句
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration *Migration, up bool) error {
+	fn := migration.Up
+	record := "insert into " + dbs.QuoteIdent(m.table) + " (`version`, `name`, `applied_at`) values (?, ?, ?)"
+	args := []any{migration.Version, migration.Name, time.Now()}
+	direction := "up"
+	if !up {
+		fn = migration.Down
+		record = "delete from " + dbs.QuoteIdent(m.table) + " where `version`=?"
+		args = []any{migration.Version}
+		direction = "down"
+	}
+	if fn == nil {
+		return fmt.Errorf("迁移 %d_%s 没有 %s", migration.Version, migration.Name, direction)
+	}
+	prefix := m.db.Prefix()
+	if migration.NoTx {
+		ex := &prefixed{ex: conn, prefix: prefix}
+		if err := fn(ctx, ex); err != nil {
+			return fmt.Errorf("迁移 %d_%s %s 失败: %w", migration.Version, migration.Name, direction, err)
+		}
+		if _, err := ex.ExecContext(ctx, record, args...); err != nil {
+			return err
+		}
+	} else {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		ex := &prefixed{ex: tx, prefix: prefix}
+		if err = fn(ctx, ex); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("迁移 %d_%s %s 失败: %w", migration.Version, migration.Name, direction, err)
+		}
+		if _, err = ex.ExecContext(ctx, record, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	logger.Printf("[MIGRATE] %s %d_%s", direction, migration.Version, migration.Name)
+	return nil
+}
+
+// sorted 按版本号排序的迁移
+func (m *Migrator) sorted() []*Migration {
+	list := make([]*Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		list = append(list, migration)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+// Up 按版本顺序执行所有未执行的迁移，返回执行的数量
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, &prefixed{ex: conn, prefix: m.db.Prefix()})
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.sorted() {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err = m.run(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// tableExists 版本表是否存在
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	name := strings.Replace(strings.ReplaceAll(m.table, "`", ""), "@pf_", m.db.Prefix(), -1)
+	rows, err := m.db.DB.QueryContext(ctx, "select 1 from information_schema.tables where table_schema=database() and table_name=?", name)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// Status 返回所有迁移的执行状态，包含已执行但找不到定义的版本
+// 只读取版本记录，不获取迁移锁也不创建版本表，版本表不存在时所有迁移均为未执行
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied := make(map[int64]Status)
+	exists, err := m.tableExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = m.applied(ctx, &prefixed{ex: m.db.DB, prefix: m.db.Prefix()}); err != nil {
+			return nil, err
+		}
+	}
+	list := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		item, ok := applied[migration.Version]
+		if !ok {
+			item = Status{Version: migration.Version, Name: migration.Name}
+		}
+		list = append(list, item)
+	}
+	for version, item := range applied {
+		if _, ok := m.migrations[version]; !ok {
+			list = append(list, item)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// lastApplied 按版本从大到小返回已执行的迁移
+func (m *Migrator) lastApplied(ctx context.Context, conn *sql.Conn, steps int) ([]*Migration, error) {
+	applied, err := m.applied(ctx, &prefixed{ex: conn, prefix: m.db.Prefix()})
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+	list := make([]*Migration, 0, len(versions))
+	for _, version := range versions {
+		migration, ok := m.migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("迁移版本 %d 已执行但找不到定义", version)
+		}
+		list = append(list, migration)
+	}
+	return list, nil
+}
+
+// Rollback 回滚最近执行的 steps 个迁移，steps 小于1时按1处理
+func (m *Migrator) Rollback(ctx context.Context, steps int) (int, error) {
+	if steps < 1 {
+		steps = 1
+	}
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		list, err := m.lastApplied(ctx, conn, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range list {
+			if err = m.run(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Redo 回滚并重新执行最近的一个迁移
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		list, err := m.lastApplied(ctx, conn, 1)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return errors.New("没有可以重做的迁移")
+		}
+		if err = m.run(ctx, conn, list[0], false); err != nil {
+			return err
+		}
+		return m.run(ctx, conn, list[0], true)
+	})
+}
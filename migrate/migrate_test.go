@@ -0,0 +1,167 @@
+package migrate
+
+import (
+	"context"
+	"github.com/wj008/goyee/dbs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseName(t *testing.T) {
+	version, title, up, ok := parseName("0012_create_user.up.sql")
+	if !ok || version != 12 || title != "create_user" || !up {
+		t.Fatalf("parse = %d %s %v %v", version, title, up, ok)
+	}
+	if _, _, up, ok = parseName("3_add-index.down.sql"); !ok || up {
+		t.Fatal("down file should be parsed")
+	}
+	for _, name := range []string{"readme.md", "0000_zero.up.sql", "a_b.up.sql", "1_x.sql"} {
+		if _, _, _, ok = parseName(name); ok {
+			t.Fatalf("%s should be ignored", name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	text := "-- 用户表\ncreate table `@pf_user` (id int); # 注释;\n" +
+		"insert into @pf_user values (1, 'a;b', \"c\\\";\", 'd''e;');\n" +
+		"/* 多行;\n注释 */ update @pf_user set name='x';;\n"
+	want := []string{
+		"create table `@pf_user` (id int)",
+		"insert into @pf_user values (1, 'a;b', \"c\\\";\", 'd''e;')",
+		"update @pf_user set name='x'",
+	}
+	if got := splitStatements(text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q", got)
+	}
+	if !hasDDL(want) || hasDDL(want[1:]) {
+		t.Fatal("ddl detection failed")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0001_create_user.up.sql":   {Data: []byte("create table @pf_user (id int);")},
+		"sql/0001_create_user.down.sql": {Data: []byte("drop table @pf_user;")},
+		"sql/0002_seed.up.sql":          {Data: []byte("insert into @pf_user values (1);")},
+		"sql/readme.md":                 {Data: []byte("ignored")},
+	}
+	m := New(nil)
+	if err := m.LoadFS(fsys, "sql"); err != nil {
+		t.Fatal(err)
+	}
+	list := m.sorted()
+	if len(list) != 2 || list[0].Version != 1 || list[1].Name != "seed" {
+		t.Fatalf("migrations = %+v", list)
+	}
+	if !list[0].NoTx || list[0].Down == nil || list[1].NoTx || list[1].Down != nil {
+		t.Fatalf("migrations = %+v %+v", list[0], list[1])
+	}
+	if err := m.LoadFS(fsys, "sql"); err == nil {
+		t.Fatal("duplicate version should be rejected")
+	}
+	bad := fstest.MapFS{"0003_x.down.sql": {Data: []byte("select 1")}}
+	if err := New(nil).LoadFS(bad, "."); err == nil {
+		t.Fatal("down without up should be rejected")
+	}
+}
+
+// filterLog 去掉加锁和读取版本的语句，只保留迁移执行的语句
+func filterLog(list []string) []string {
+	items := make([]string, 0)
+	for _, query := range list {
+		if strings.HasPrefix(query, "select") || strings.HasPrefix(query, "create table if not exists") {
+			continue
+		}
+		if strings.HasPrefix(query, "insert into `t_schema_migrations`") {
+			query = "record"
+		} else if strings.HasPrefix(query, "delete from `t_schema_migrations`") {
+			query = "unrecord"
+		}
+		items = append(items, query)
+	}
+	return items
+}
+
+func TestUpRollback(t *testing.T) {
+	srv := newFakeServer()
+	m := New(dbs.Register("migrate_order", srv.db(), "t_"))
+	defer dbs.Close("migrate_order")
+	fsys := fstest.MapFS{
+		"0002_seed.up.sql":    {Data: []byte("insert into @pf_user values (1);")},
+		"0002_seed.down.sql":  {Data: []byte("delete from @pf_user;")},
+		"0001_user.up.sql":    {Data: []byte("create table @pf_user (id int);")},
+		"0001_user.down.sql":  {Data: []byte("drop table @pf_user;")},
+		"0003_index.up.sql":   {Data: []byte("alter table @pf_user add index (id);")},
+		"0003_index.down.sql": {Data: []byte("alter table @pf_user drop index id;")},
+	}
+	if err := m.LoadFS(fsys, "."); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if n, err := m.Up(ctx); err != nil || n != 3 {
+		t.Fatalf("up = %d, %v", n, err)
+	}
+	want := []string{
+		"create table t_user (id int)", "record",
+		"BEGIN", "insert into t_user values (1)", "record", "COMMIT",
+		"alter table t_user add index (id)", "record",
+	}
+	if got := filterLog(srv.statements()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("up statements = %q", got)
+	}
+	srv.log = nil
+	if n, err := m.Rollback(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("rollback = %d, %v", n, err)
+	}
+	want = []string{
+		"alter table t_user drop index id", "unrecord",
+		"BEGIN", "delete from t_user", "unrecord", "COMMIT",
+	}
+	if got := filterLog(srv.statements()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rollback statements = %q", got)
+	}
+	list, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || !list[0].Applied || list[1].Applied || list[2].Applied {
+		t.Fatalf("status = %+v", list)
+	}
+}
+
+func TestStatusWithoutTable(t *testing.T) {
+	srv := newFakeServer()
+	m := New(dbs.Register("migrate_status", srv.db(), "t_"))
+	defer dbs.Close("migrate_status")
+	m.Add(&Migration{Version: 1, Name: "init", Up: sqlFunc([]string{"select 1"})})
+	list, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Applied {
+		t.Fatalf("status = %+v", list)
+	}
+	for _, query := range srv.statements() {
+		if strings.Contains(query, "GET_LOCK") || strings.HasPrefix(query, "create") {
+			t.Fatalf("status should only read: %s", query)
+		}
+	}
+}
+
+func TestNoTxError(t *testing.T) {
+	srv := newFakeServer()
+	srv.fail = "t_role"
+	m := New(dbs.Register("migrate_notx", srv.db(), "t_"))
+	defer dbs.Close("migrate_notx")
+	m.Add(&Migration{Version: 1, Name: "init", NoTx: true, Up: sqlFunc([]string{"create table @pf_user (id int)", "create table @pf_role (id int)"})})
+	_, err := m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "第 2 条语句") || !strings.Contains(err.Error(), "create table @pf_role (id int)") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(srv.applied) != 0 {
+		t.Fatal("failed migration should not be recorded")
+	}
+}
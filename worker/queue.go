@@ -14,7 +14,6 @@ func NewQueue(workerCount int, fn func(...any)) *Queue {
 		job: make(chan []any, workerCount),
 	}
 	worker := func(jobChan <-chan []any, workId int) {
-		q.wg.Add(1)
 		defer q.wg.Done()
 		for value := range jobChan {
 			if fn != nil {
@@ -23,6 +22,7 @@ func NewQueue(workerCount int, fn func(...any)) *Queue {
 		}
 	}
 	for i := 0; i < workerCount; i++ {
+		q.wg.Add(1)
 		go worker(q.job, i)
 	}
 	return q